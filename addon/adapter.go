@@ -117,6 +117,7 @@ func newAdapter() (adapter *Adapter) {
 	//
 	// Build REST client.
 	client := binding.NewClient(Settings.Addon.Hub.URL, Settings.Addon.Hub.Token)
+	client.SetRefreshToken(Settings.Addon.Hub.Refresh)
 	//
 	// Build Adapter.
 	adapter = &Adapter{
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -97,8 +98,11 @@ func (h AddonHandler) List(ctx *gin.Context) {
 //
 // Addon REST resource.
 type Addon struct {
-	Name  string `json:"name"`
-	Image string `json:"image"`
+	Name     string         `json:"name"`
+	Image    string         `json:"image"`
+	Kind     string         `json:"kind,omitempty"`
+	Selector *AddonSelector `json:"selector,omitempty"`
+	Schema   interface{}    `json:"schema,omitempty" swaggertype:"object"`
 }
 
 //
@@ -106,4 +110,21 @@ type Addon struct {
 func (r *Addon) With(m *crd.Addon) {
 	r.Name = m.Name
 	r.Image = m.Spec.Image
+	r.Kind = m.Spec.Kind
+	if m.Spec.Selector != nil {
+		r.Selector = &AddonSelector{
+			Tags:  m.Spec.Selector.Tags,
+			Facts: m.Spec.Selector.Facts,
+		}
+	}
+	if m.Spec.Schema != nil {
+		_ = json.Unmarshal(m.Spec.Schema.Raw, &r.Schema)
+	}
+}
+
+//
+// AddonSelector selects applications by tags and facts.
+type AddonSelector struct {
+	Tags  []string `json:"tags,omitempty"`
+	Facts []string `json:"facts,omitempty"`
 }
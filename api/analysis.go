@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	qf "github.com/konveyor/tackle2-hub/api/filter"
 	"github.com/konveyor/tackle2-hub/model"
@@ -11,6 +12,9 @@ import (
 	"gorm.io/gorm/logger"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 )
 
 //
@@ -21,6 +25,7 @@ const (
 	AnalysesDepsRoot      = AnalysesRoot + "/dependencies"
 	AnalysesIssuesRoot    = AnalysesRoot + "/issues"
 	AnalysesIssueRoot     = AnalysesIssuesRoot + "/:" + ID
+	AnalysesSarifRoot     = AnalysesRoot + "/sarif"
 	AnalysisIncidentsRoot = AnalysesIssueRoot + "/incidents"
 	//
 	AnalysesReportRoot           = AnalysesRoot + "/report"
@@ -33,11 +38,14 @@ const (
 	AnalysisReportDepsAppsRoot   = AnalysisReportDepsRoot + "/applications"
 	AnalysisReportAppsIssuesRoot = AnalysisReportAppsRoot + "/:" + ID + "/issues"
 	AnalysisReportFileRoot       = AnalysisReportIssueRoot + "/files"
+	AnalysisReportArchiveRoot    = AnalysesReportRoot + "/archive"
 	//
 	AppAnalysesRoot       = ApplicationRoot + "/analyses"
 	AppAnalysisRoot       = ApplicationRoot + "/analysis"
 	AppAnalysisDepsRoot   = AppAnalysisRoot + "/dependencies"
 	AppAnalysisIssuesRoot = AppAnalysisRoot + "/issues"
+	AppAnalysisDiffRoot   = AppAnalysisRoot + "/diff"
+	AppAnalysisReportRoot = AppAnalysisRoot + "/report"
 )
 
 const (
@@ -63,6 +71,7 @@ func (h AnalysisHandler) AddRoutes(e *gin.Engine) {
 	routeGroup.GET(AnalysesIssuesRoot, h.Issues)
 	routeGroup.GET(AnalysesIssueRoot, h.Issue)
 	routeGroup.GET(AnalysisIncidentsRoot, h.Incidents)
+	routeGroup.GET(AnalysesSarifRoot, h.Sarif)
 	//
 	routeGroup.GET(AnalysisReportRuleRoot, h.RuleReports)
 	routeGroup.GET(AnalysisReportAppsIssuesRoot, h.AppIssueReports)
@@ -70,17 +79,21 @@ func (h AnalysisHandler) AddRoutes(e *gin.Engine) {
 	routeGroup.GET(AnalysisReportFileRoot, h.FileReports)
 	routeGroup.GET(AnalysisReportDepsRoot, h.DepReports)
 	routeGroup.GET(AnalysisReportDepsAppsRoot, h.DepAppReports)
+	routeGroup.GET(AnalysisReportArchiveRoot, h.ReportArchive)
 	//
-	routeGroup.POST(AppAnalysesRoot, h.AppCreate)
+	routeGroup.POST(AppAnalysesRoot, Transaction, h.AppCreate)
 	routeGroup.GET(AppAnalysesRoot, h.AppList)
 	routeGroup.GET(AppAnalysisRoot, h.AppLatest)
 	routeGroup.GET(AppAnalysisDepsRoot, h.AppDeps)
 	routeGroup.GET(AppAnalysisIssuesRoot, h.AppIssues)
+	routeGroup.GET(AppAnalysisDiffRoot, h.AppDiff)
+	routeGroup.GET(AppAnalysisReportRoot, h.AppReportArchive)
 }
 
 // Get godoc
 // @summary Get an analysis (report) by ID.
 // @description Get an analysis (report) by ID.
+// @description Exported as SARIF when accepted (application/sarif+json).
 // @tags analyses
 // @produce json
 // @success 200 {object} api.Analysis
@@ -89,14 +102,30 @@ func (h AnalysisHandler) AddRoutes(e *gin.Engine) {
 func (h AnalysisHandler) Get(ctx *gin.Context) {
 	id := h.pk(ctx)
 	m := &model.Analysis{}
+	if h.Accepted(ctx, MIMESARIF) {
+		result := h.DB(ctx).Select("ID").First(m, id)
+		if result.Error != nil {
+			_ = ctx.Error(result.Error)
+			return
+		}
+		h.sarif(ctx, m.ID)
+		return
+	}
 	db := h.preLoad(h.DB(ctx), clause.Associations)
 	result := db.First(m, id)
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
 		return
 	}
+	waived := WaivedIssues{}
+	err := waived.With(h.DB(ctx), m.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
 	r := Analysis{}
 	r.With(m)
+	r.Waive(&waived, h.waivedIncluded(ctx))
 
 	h.Respond(ctx, http.StatusOK, r)
 }
@@ -104,6 +133,7 @@ func (h AnalysisHandler) Get(ctx *gin.Context) {
 // AppLatest godoc
 // @summary Get the latest analysis.
 // @description Get the latest analysis for an application.
+// @description Exported as SARIF when accepted (application/sarif+json).
 // @tags analyses
 // @produce json
 // @success 200 {object} api.Analysis
@@ -112,6 +142,17 @@ func (h AnalysisHandler) Get(ctx *gin.Context) {
 func (h AnalysisHandler) AppLatest(ctx *gin.Context) {
 	id := h.pk(ctx)
 	m := &model.Analysis{}
+	if h.Accepted(ctx, MIMESARIF) {
+		db := h.DB(ctx).Select("ID")
+		db = db.Where("ApplicationID = ?", id)
+		result := db.Last(m)
+		if result.Error != nil {
+			_ = ctx.Error(result.Error)
+			return
+		}
+		h.sarif(ctx, m.ID)
+		return
+	}
 	db := h.preLoad(h.DB(ctx), clause.Associations)
 	db = db.Where("ApplicationID = ?", id)
 	result := db.Last(m)
@@ -119,8 +160,15 @@ func (h AnalysisHandler) AppLatest(ctx *gin.Context) {
 		_ = ctx.Error(result.Error)
 		return
 	}
+	waived := WaivedIssues{}
+	err := waived.With(h.DB(ctx), m.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
 	r := Analysis{}
 	r.With(m)
+	r.Waive(&waived, h.waivedIncluded(ctx))
 
 	h.Respond(ctx, http.StatusOK, r)
 }
@@ -170,10 +218,23 @@ func (h AnalysisHandler) AppList(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	ids := []uint{}
+	for i := range list {
+		ids = append(ids, list[i].ID)
+	}
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), ids)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	included := h.waivedIncluded(ctx)
 	// Render
 	for i := range list {
 		r := Analysis{}
 		r.With(&list[i])
+		r.Waive(&waived, included)
 		resources = append(resources, r)
 	}
 
@@ -187,6 +248,9 @@ func (h AnalysisHandler) AppList(ctx *gin.Context) {
 // @description   - file: file that contains the api.Analysis resource.
 // @description   - issues: file that multiple api.Issue resources.
 // @description   - dependencies: file that multiple api.TechDependency resources.
+// @description The analysis is created atomically and the issues and dependencies
+// @description are created in batches. A document record that cannot be decoded
+// @description is reported by document, (record) index and field.
 // @tags analyses
 // @produce json
 // @success 201 {object} api.Analysis
@@ -210,107 +274,96 @@ func (h AnalysisHandler) AppCreate(ctx *gin.Context) {
 	}
 	//
 	// Analysis
-	input, err := ctx.FormFile(FileField)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
-	reader, err := input.Open()
+	d, closer, err := h.document(ctx, FileField)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	defer func() {
-		_ = reader.Close()
-	}()
-	encoding := input.Header.Get(ContentType)
-	d, err := h.Decoder(ctx, encoding, reader)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
+	defer closer()
 	r := Analysis{}
 	err = d.Decode(&r)
 	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
+		dErr := &DecodeError{Document: FileField}
+		dErr.With(err)
+		_ = ctx.Error(dErr)
 		return
 	}
 	//
 	// Issues
-	input, err = ctx.FormFile(IssueField)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
-	reader, err = input.Open()
+	d, closer, err = h.document(ctx, IssueField)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	defer func() {
-		_ = reader.Close()
-	}()
-	encoding = input.Header.Get(ContentType)
-	d, err = h.Decoder(ctx, encoding, reader)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
-	for {
+	defer closer()
+	issues := []model.Issue{}
+	for index := 0; ; index++ {
 		r := &Issue{}
 		err = d.Decode(r)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
-			} else {
-				h.Status(ctx, http.StatusBadRequest)
-				return
 			}
+			dErr := &DecodeError{Document: IssueField, Index: index}
+			dErr.With(err)
+			_ = ctx.Error(dErr)
+			return
 		}
 		m := r.Model()
 		m.AnalysisID = analysis.ID
-		err = db.Create(m).Error
+		issues = append(issues, *m)
+		analysis.Effort += r.Effort * len(r.Incidents)
+		if len(issues) == db.CreateBatchSize {
+			err = db.Create(&issues).Error
+			if err != nil {
+				_ = ctx.Error(err)
+				return
+			}
+			issues = []model.Issue{}
+		}
+	}
+	if len(issues) > 0 {
+		err = db.Create(&issues).Error
 		if err != nil {
 			_ = ctx.Error(err)
 			return
 		}
-		analysis.Effort += r.Effort * len(r.Incidents)
 	}
 	//
 	// Dependencies
-	input, err = ctx.FormFile(DepField)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
-	reader, err = input.Open()
+	d, closer, err = h.document(ctx, DepField)
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
-	defer func() {
-		_ = reader.Close()
-	}()
-	encoding = input.Header.Get(ContentType)
-	d, err = h.Decoder(ctx, encoding, reader)
-	if err != nil {
-		h.Status(ctx, http.StatusBadRequest)
-		return
-	}
-	for {
+	defer closer()
+	deps := []model.TechDependency{}
+	for index := 0; ; index++ {
 		r := &TechDependency{}
 		err = d.Decode(r)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
-			} else {
-				h.Status(ctx, http.StatusBadRequest)
-				return
 			}
+			dErr := &DecodeError{Document: DepField, Index: index}
+			dErr.With(err)
+			_ = ctx.Error(dErr)
+			return
 		}
 		m := r.Model()
 		m.AnalysisID = analysis.ID
-		err = db.Create(m).Error
+		deps = append(deps, *m)
+		if len(deps) == db.CreateBatchSize {
+			err = db.Create(&deps).Error
+			if err != nil {
+				_ = ctx.Error(err)
+				return
+			}
+			deps = []model.TechDependency{}
+		}
+	}
+	if len(deps) > 0 {
+		err = db.Create(&deps).Error
 		if err != nil {
 			_ = ctx.Error(err)
 			return
@@ -361,6 +414,79 @@ func (h AnalysisHandler) Delete(ctx *gin.Context) {
 	h.Status(ctx, http.StatusNoContent)
 }
 
+// AppDiff godoc
+// @summary Compare analyses.
+// @description Compare two analyses for an application.
+// @description Defaults to the latest analysis compared with the previous analysis.
+// @description Issues are matched by ruleset and rule.
+// @description Dependencies are matched by provider, name and version.
+// @description A dependency is upgraded when a single version is replaced
+// @description by another version, or when the SHA changed.
+// @description Query params:
+// @description   - from: the analysis ID compared with.
+// @description   - to: the analysis ID compared.
+// @tags analyses
+// @produce json
+// @success 200 {object} api.AnalysisDiff
+// @router /applications/{id}/analysis/diff [get]
+// @param id path string true "Application ID"
+func (h AnalysisHandler) AppDiff(ctx *gin.Context) {
+	id := h.pk(ctx)
+	to, err := h.appAnalysis(ctx, id, ctx.Query("to"), 0)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	from, err := h.appAnalysis(ctx, id, ctx.Query("from"), to.ID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) || ctx.Query("from") != "" {
+			_ = ctx.Error(err)
+			return
+		}
+		from = &model.Analysis{}
+	}
+	r := AnalysisDiff{}
+	r.With(from, to)
+
+	h.Respond(ctx, http.StatusOK, r)
+}
+
+// Sarif godoc
+// @summary Export analyses as SARIF.
+// @description Export the latest analysis for each application as SARIF (2.1.0).
+// @description Each analysis is reported as a run.
+// @description Waived incidents are reported as suppressed.
+// @description A filter is required.
+// @description filters:
+// @description - application.id
+// @description - application.name
+// @description - businessService.id
+// @description - businessService.name
+// @description - tag.id
+// @tags analyses
+// @produce application/sarif+json
+// @success 200 {object} api.Sarif
+// @router /analyses/sarif [get]
+func (h AnalysisHandler) Sarif(ctx *gin.Context) {
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "application.id", Kind: qf.LITERAL},
+			{Field: "application.name", Kind: qf.STRING},
+			{Field: "businessService.id", Kind: qf.LITERAL},
+			{Field: "businessService.name", Kind: qf.STRING},
+			{Field: "tag.id", Kind: qf.LITERAL, Relation: true},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	if filter.Empty() {
+		_ = ctx.Error(&BadRequestError{"filter required."})
+		return
+	}
+	h.sarif(ctx, h.analysisIDs(ctx, filter))
+}
+
 // AppDeps godoc
 // @summary List application dependencies.
 // @description List application dependencies.
@@ -489,11 +615,21 @@ func (h AnalysisHandler) AppIssues(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), analysis.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
 	// Find
 	db = h.DB(ctx)
 	db = db.Model(&model.Issue{})
 	db = db.Where("AnalysisID = ?", analysis.ID)
 	db = db.Where("ID IN (?)", h.issueIDs(ctx, filter))
+	if !h.waivedIncluded(ctx) && waived.Issues() != nil {
+		db = db.Where("ID NOT IN (?)", waived.Issues())
+	}
 	db = sort.Sorted(db)
 	var list []model.Issue
 	var m model.Issue
@@ -521,6 +657,7 @@ func (h AnalysisHandler) AppIssues(ctx *gin.Context) {
 		m := &list[i]
 		r := Issue{}
 		r.With(m)
+		r.Waived = waived.Waived(m.ID)
 		resources = append(resources, r)
 	}
 
@@ -570,6 +707,13 @@ func (h AnalysisHandler) Issues(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), h.analysisIDs(ctx, filter))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
 	// Find
 	db := h.DB(ctx)
 	db = db.Table("Issue i")
@@ -577,6 +721,9 @@ func (h AnalysisHandler) Issues(ctx *gin.Context) {
 	db = db.Where("a.ID = i.AnalysisID")
 	db = db.Where("a.ID IN (?)", h.analysisIDs(ctx, filter))
 	db = db.Where("i.ID IN (?)", h.issueIDs(ctx, filter))
+	if !h.waivedIncluded(ctx) && waived.Issues() != nil {
+		db = db.Where("i.ID NOT IN (?)", waived.Issues())
+	}
 	db = db.Group("i.ID")
 	db = sort.Sorted(db)
 	var list []model.Issue
@@ -605,6 +752,7 @@ func (h AnalysisHandler) Issues(ctx *gin.Context) {
 		m := &list[i]
 		r := Issue{}
 		r.With(m)
+		r.Waived = waived.Waived(m.ID)
 		resources = append(resources, r)
 	}
 
@@ -758,24 +906,19 @@ func (h AnalysisHandler) RuleReports(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), h.analysisIDs(ctx, filter))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var excluded *gorm.DB
+	if !h.waivedIncluded(ctx) {
+		excluded = waived.Issues()
+	}
 	// Inner Query
-	q := h.DB(ctx)
-	q = q.Select(
-		"i.RuleSet",
-		"i.Rule",
-		"i.Name",
-		"i.Description",
-		"i.Category",
-		"i.Effort",
-		"i.Labels",
-		"i.Links",
-		"COUNT(distinct a.ID) Applications")
-	q = q.Table("Issue i,")
-	q = q.Joins("Analysis a")
-	q = q.Where("a.ID = i.AnalysisID")
-	q = q.Where("a.ID in (?)", h.analysisIDs(ctx, filter))
-	q = q.Where("i.ID IN (?)", h.issueIDs(ctx, filter))
-	q = q.Group("i.RuleSet,i.Rule")
+	q := h.ruleReports(ctx, filter, h.analysisIDs(ctx, filter), excluded)
 	// Find
 	db := h.DB(ctx)
 	db = db.Select("*")
@@ -805,22 +948,9 @@ func (h AnalysisHandler) RuleReports(ctx *gin.Context) {
 	// Render
 	for i := range list {
 		m := list[i]
-		r := &RuleReport{
-			Applications: m.Applications,
-			Description:  m.Description,
-			Category:     m.Category,
-			RuleSet:      m.RuleSet,
-			Rule:         m.Rule,
-			Name:         m.Name,
-		}
+		r := &RuleReport{}
+		r.With(&m.Issue, m.Applications)
 		resources = append(resources, r)
-		if m.Labels != nil {
-			_ = json.Unmarshal(m.Labels, &r.Labels)
-		}
-		if m.Links != nil {
-			_ = json.Unmarshal(m.Links, &r.Links)
-		}
-		r.Effort += m.Effort
 	}
 
 	h.Respond(ctx, http.StatusOK, resources)
@@ -881,25 +1011,19 @@ func (h AnalysisHandler) AppIssueReports(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), analysis.ID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var excluded *gorm.DB
+	if !h.waivedIncluded(ctx) {
+		excluded = waived.Incidents()
+	}
 	// Inner Query
-	q := h.DB(ctx)
-	q = q.Select(
-		"i.ID",
-		"i.RuleSet",
-		"i.Rule",
-		"i.Name",
-		"i.Description",
-		"i.Category",
-		"i.Effort",
-		"i.Labels",
-		"i.Links",
-		"COUNT(distinct n.File) Files")
-	q = q.Table("Issue i,")
-	q = q.Joins("Incident n")
-	q = q.Where("i.ID = n.IssueID")
-	q = q.Where("i.ID IN (?)", h.issueIDs(ctx, filter))
-	q = q.Where("i.AnalysisID", analysis.ID)
-	q = q.Group("i.RuleSet,i.Rule")
+	q := h.issueReports(ctx, filter, analysis.ID, excluded)
 	// Find
 	db = h.DB(ctx)
 	db = db.Select("*")
@@ -929,23 +1053,9 @@ func (h AnalysisHandler) AppIssueReports(ctx *gin.Context) {
 	// Render
 	for i := range list {
 		m := list[i]
-		r := &IssueReport{
-			Files:       m.Files,
-			Description: m.Description,
-			Category:    m.Category,
-			RuleSet:     m.RuleSet,
-			Rule:        m.Rule,
-			Name:        m.Name,
-			ID:          m.ID,
-		}
+		r := &IssueReport{}
+		r.With(&m.Issue, m.Files)
 		resources = append(resources, r)
-		if m.Labels != nil {
-			_ = json.Unmarshal(m.Labels, &r.Labels)
-		}
-		if m.Links != nil {
-			_ = json.Unmarshal(m.Links, &r.Links)
-		}
-		r.Effort += m.Effort
 	}
 
 	h.Respond(ctx, http.StatusOK, resources)
@@ -1155,18 +1265,19 @@ func (h AnalysisHandler) FileReports(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	// Waived
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), issue.AnalysisID)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var excluded *gorm.DB
+	if !h.waivedIncluded(ctx) {
+		excluded = waived.Incidents()
+	}
 	// Inner Query
-	q := h.DB(ctx)
-	q = q.Model(&model.Incident{})
-	q = q.Select(
-		"IssueId",
-		"File",
-		"Effort*COUNT(Incident.id) Effort",
-		"COUNT(Incident.id) Incidents")
-	q = q.Joins(",Issue")
-	q = q.Where("Issue.ID = IssueID")
-	q = q.Where("Issue.ID", issueId)
-	q = q.Group("File")
+	q := h.fileReports(ctx, issueId, excluded)
 	// Find
 	db := h.DB(ctx)
 	db = db.Select("*")
@@ -1209,6 +1320,96 @@ func (h AnalysisHandler) FileReports(ctx *gin.Context) {
 	h.Respond(ctx, http.StatusOK, resources)
 }
 
+// ReportArchive godoc
+// @summary Export the analysis report archive.
+// @description Export a static report for the latest analysis of each application.
+// @description The archive (tar.gz) contains:
+// @description - index.html
+// @description - issues.csv
+// @description - incidents.csv
+// @description - dependencies.csv
+// @description Waived issues and incidents are excluded unless waived=true.
+// @description A filter is required.
+// @description filters:
+// @description - ruleset
+// @description - rule
+// @description - category
+// @description - labels
+// @description - application.id
+// @description - application.name
+// @description - businessService.id
+// @description - businessService.name
+// @description - tag.id
+// @tags analyses
+// @produce application/gzip
+// @success 200
+// @router /analyses/report/archive [get]
+func (h AnalysisHandler) ReportArchive(ctx *gin.Context) {
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "ruleset", Kind: qf.STRING},
+			{Field: "rule", Kind: qf.STRING},
+			{Field: "category", Kind: qf.STRING},
+			{Field: "labels", Kind: qf.STRING, Relation: true},
+			{Field: "application.id", Kind: qf.LITERAL},
+			{Field: "application.name", Kind: qf.STRING},
+			{Field: "businessService.id", Kind: qf.LITERAL},
+			{Field: "businessService.name", Kind: qf.STRING},
+			{Field: "tag.id", Kind: qf.LITERAL, Relation: true},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	if filter.Empty() {
+		_ = ctx.Error(&BadRequestError{"filter required."})
+		return
+	}
+	h.archive(ctx, filter, h.analysisIDs(ctx, filter))
+}
+
+// AppReportArchive godoc
+// @summary Export the application analysis report archive.
+// @description Export a static report for the latest application analysis.
+// @description The archive (tar.gz) contains:
+// @description - index.html
+// @description - issues.csv
+// @description - incidents.csv
+// @description - dependencies.csv
+// @description Waived issues and incidents are excluded unless waived=true.
+// @description filters:
+// @description - ruleset
+// @description - rule
+// @description - category
+// @description - labels
+// @tags analyses
+// @produce application/gzip
+// @success 200
+// @router /applications/{id}/analysis/report [get]
+// @param id path string true "Application ID"
+func (h AnalysisHandler) AppReportArchive(ctx *gin.Context) {
+	id := h.pk(ctx)
+	analysis := &model.Analysis{}
+	db := h.DB(ctx).Where("ApplicationID", id)
+	result := db.Last(analysis)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "ruleset", Kind: qf.STRING},
+			{Field: "rule", Kind: qf.STRING},
+			{Field: "category", Kind: qf.STRING},
+			{Field: "labels", Kind: qf.STRING, Relation: true},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	h.archive(ctx, filter, analysis.ID)
+}
+
 // Deps godoc
 // @summary List dependencies.
 // @description List dependencies.
@@ -1345,18 +1546,7 @@ func (h AnalysisHandler) DepReports(ctx *gin.Context) {
 		return
 	}
 	// Inner Query
-	q := h.DB(ctx)
-	q = q.Select(
-		"Provider",
-		"Name",
-		"Version",
-		"SHA",
-		"Labels",
-		"COUNT(distinct AnalysisID) Applications")
-	q = q.Model(&model.TechDependency{})
-	q = q.Where("AnalysisID IN (?)", h.analysisIDs(ctx, filter))
-	q = q.Where("ID IN (?)", h.depIDs(ctx, filter))
-	q = q.Group("Name,SHA")
+	q := h.depReports(ctx, filter, h.analysisIDs(ctx, filter))
 	// Find
 	db := h.DB(ctx)
 	db = db.Select("*")
@@ -1386,16 +1576,8 @@ func (h AnalysisHandler) DepReports(ctx *gin.Context) {
 	// Render
 	for i := range list {
 		m := &list[i]
-		r := DepReport{
-			Provider:     m.Provider,
-			Name:         m.Name,
-			Version:      m.Version,
-			SHA:          m.SHA,
-			Applications: m.Applications,
-		}
-		if m.Labels != nil {
-			_ = json.Unmarshal(m.Labels, &r.Labels)
-		}
+		r := DepReport{}
+		r.With(&m.TechDependency, m.Applications)
 		resources = append(resources, r)
 	}
 
@@ -1687,9 +1869,423 @@ func (h *AnalysisHandler) depIDs(ctx *gin.Context, f qf.Filter) (q *gorm.DB) {
 }
 
 //
-// Analysis REST resource.
-type Analysis struct {
-	Resource     `yaml:",inline"`
+// document returns a decoder for the (form) file document.
+// The closer must be called to close the file.
+func (h *AnalysisHandler) document(ctx *gin.Context, field string) (d Decoder, closer func(), err error) {
+	closer = func() {}
+	input, err := ctx.FormFile(field)
+	if err != nil {
+		err = &BadRequestError{
+			Reason: field + ": " + err.Error(),
+		}
+		return
+	}
+	reader, err := input.Open()
+	if err != nil {
+		return
+	}
+	closer = func() {
+		_ = reader.Close()
+	}
+	encoding := input.Header.Get(ContentType)
+	d, err = h.Decoder(ctx, encoding, reader)
+	if err != nil {
+		err = &BadRequestError{
+			Reason: field + ": " + err.Error(),
+		}
+		return
+	}
+	return
+}
+
+//
+// ruleReports returns the rule report (inner) query.
+// Issues are collated by ruleset/rule.
+// The analyses may be an ID, list of IDs or a (sub)query.
+// Filter:
+//  issue.*
+// Excluded: issue IDs.
+func (h *AnalysisHandler) ruleReports(ctx *gin.Context, f qf.Filter, analyses interface{}, excluded *gorm.DB) (q *gorm.DB) {
+	q = h.DB(ctx)
+	q = q.Select(
+		"i.RuleSet",
+		"i.Rule",
+		"i.Name",
+		"i.Description",
+		"i.Category",
+		"i.Effort",
+		"i.Labels",
+		"i.Links",
+		"COUNT(distinct a.ID) Applications")
+	q = q.Table("Issue i,")
+	q = q.Joins("Analysis a")
+	q = q.Where("a.ID = i.AnalysisID")
+	q = q.Where("a.ID in (?)", analyses)
+	q = q.Where("i.ID IN (?)", h.issueIDs(ctx, f))
+	if excluded != nil {
+		q = q.Where("i.ID NOT IN (?)", excluded)
+	}
+	q = q.Group("i.RuleSet,i.Rule")
+	return
+}
+
+//
+// issueReports returns the issue report (inner) query.
+// Issues within the analysis are collated by ruleset/rule.
+// Filter:
+//  issue.*
+// Excluded: incident IDs.
+func (h *AnalysisHandler) issueReports(ctx *gin.Context, f qf.Filter, analysis uint, excluded *gorm.DB) (q *gorm.DB) {
+	q = h.DB(ctx)
+	q = q.Select(
+		"i.ID",
+		"i.RuleSet",
+		"i.Rule",
+		"i.Name",
+		"i.Description",
+		"i.Category",
+		"i.Effort",
+		"i.Labels",
+		"i.Links",
+		"COUNT(distinct n.File) Files")
+	q = q.Table("Issue i,")
+	q = q.Joins("Incident n")
+	q = q.Where("i.ID = n.IssueID")
+	q = q.Where("i.ID IN (?)", h.issueIDs(ctx, f))
+	q = q.Where("i.AnalysisID", analysis)
+	if excluded != nil {
+		q = q.Where("n.ID NOT IN (?)", excluded)
+	}
+	q = q.Group("i.RuleSet,i.Rule")
+	return
+}
+
+//
+// fileReports returns the file report (inner) query.
+// Incidents within the issue are collated by file.
+// Excluded: incident IDs.
+func (h *AnalysisHandler) fileReports(ctx *gin.Context, issue uint, excluded *gorm.DB) (q *gorm.DB) {
+	q = h.DB(ctx)
+	q = q.Model(&model.Incident{})
+	q = q.Select(
+		"IssueId",
+		"File",
+		"Effort*COUNT(Incident.id) Effort",
+		"COUNT(Incident.id) Incidents")
+	q = q.Joins(",Issue")
+	q = q.Where("Issue.ID = IssueID")
+	q = q.Where("Issue.ID", issue)
+	if excluded != nil {
+		q = q.Where("Incident.ID NOT IN (?)", excluded)
+	}
+	q = q.Group("File")
+	return
+}
+
+//
+// depReports returns the dependency report (inner) query.
+// Dependencies are collated by name/sha.
+// The analyses may be an ID, list of IDs or a (sub)query.
+// Filter:
+//  techDeps.*
+func (h *AnalysisHandler) depReports(ctx *gin.Context, f qf.Filter, analyses interface{}) (q *gorm.DB) {
+	q = h.DB(ctx)
+	q = q.Select(
+		"Provider",
+		"Name",
+		"Version",
+		"SHA",
+		"Labels",
+		"COUNT(distinct AnalysisID) Applications")
+	q = q.Model(&model.TechDependency{})
+	q = q.Where("AnalysisID IN (?)", analyses)
+	q = q.Where("ID IN (?)", h.depIDs(ctx, f))
+	q = q.Group("Name,SHA")
+	return
+}
+
+//
+// sarif responds with the analyses exported as SARIF.
+// The analyses may be an ID, list of IDs or a (sub)query.
+// The analyses are fetched and encoded (as runs) one at
+// a time.
+func (h *AnalysisHandler) sarif(ctx *gin.Context, analyses interface{}) {
+	var ids []uint
+	db := h.DB(ctx)
+	db = db.Model(&model.Analysis{})
+	db = db.Where("ID IN (?)", analyses)
+	db = db.Order("ID")
+	err := db.Pluck("ID", &ids).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	waived := WaivedIssues{}
+	err = waived.With(h.DB(ctx), analyses)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.Header(ContentType, MIMESARIF)
+	ctx.Status(http.StatusOK)
+	writer := SarifWriter{}
+	err = writer.Begin(ctx.Writer)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	for _, id := range ids {
+		m := &model.Analysis{}
+		db = h.DB(ctx)
+		db = db.Preload("Application")
+		db = db.Preload("Issues.Incidents")
+		err = db.First(m, id).Error
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+		run := SarifRun{}
+		run.With(m, &waived)
+		err = writer.Write(&run)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
+	err = writer.End()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+}
+
+//
+// archive responds with the report archive for the analyses.
+// The report is built using the report (inner) queries.
+// Each analysis is fetched and rendered in turn.
+// The analyses may be an ID, list of IDs or a (sub)query.
+// Filter:
+//  issue.*
+func (h *AnalysisHandler) archive(ctx *gin.Context, f qf.Filter, analyses interface{}) {
+	waived := WaivedIssues{}
+	err := waived.With(h.DB(ctx), analyses)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	included := h.waivedIncluded(ctx)
+	var issues, incidents *gorm.DB
+	if !included {
+		issues = waived.Issues()
+		incidents = waived.Incidents()
+	}
+	r := ReportArchive{Created: time.Now()}
+	err = r.Open()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	defer r.Close()
+	// Rules
+	var rules []struct {
+		model.Issue
+		Applications int
+	}
+	db := h.DB(ctx)
+	db = db.Table("(?)", h.ruleReports(ctx, f, analyses, issues))
+	db = db.Order("Category,RuleSet,Rule")
+	err = db.Find(&rules).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	for i := range rules {
+		m := &rules[i]
+		rule := RuleReport{}
+		rule.With(&m.Issue, m.Applications)
+		r.Rules = append(r.Rules, rule)
+	}
+	// Dependencies
+	deps, err := h.archiveDeps(ctx, analyses)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	r.Dependencies = deps
+	// Applications
+	var list []model.Analysis
+	db = h.DB(ctx)
+	db = db.Preload("Application")
+	db = db.Where("ID IN (?)", analyses)
+	db = db.Order("ApplicationID")
+	err = db.Find(&list).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	for i := range list {
+		m := &list[i]
+		app := ReportApp{
+			Analysis: m.ID,
+			Effort:   m.Effort,
+		}
+		if m.Application != nil {
+			app.ID = m.Application.ID
+			app.Name = m.Application.Name
+		}
+		if !included {
+			app.Effort -= waived.Effort[m.ID]
+		}
+		app.Issues, err = h.archiveIssues(ctx, f, m.ID, incidents)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+		app.Dependencies, err = h.archiveDeps(ctx, m.ID)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+		err = r.Add(&app)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
+	// Render
+	ctx.Header(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"%s\"", ReportArchiveName+".tar.gz"))
+	ctx.Header(ContentType, MIMEGZIP)
+	err = r.Write(ctx.Writer)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+}
+
+//
+// archiveIssues returns the (report) issues within the analysis.
+// The incidents within the analysis are fetched in one query
+// and collated by issue and file.
+// Excluded: incident IDs.
+func (h *AnalysisHandler) archiveIssues(ctx *gin.Context, f qf.Filter, analysis uint, excluded *gorm.DB) (issues []ReportIssue, err error) {
+	var list []struct {
+		model.Issue
+		Files int
+	}
+	db := h.DB(ctx)
+	db = db.Table("(?)", h.issueReports(ctx, f, analysis, excluded))
+	db = db.Order("Category,RuleSet,Rule")
+	err = db.Find(&list).Error
+	if err != nil {
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+	issues = make([]ReportIssue, len(list))
+	byID := make(map[uint]*ReportIssue)
+	for i := range list {
+		m := &list[i]
+		issue := &issues[i]
+		issue.IssueReport.With(&m.Issue, m.Files)
+		byID[m.ID] = issue
+	}
+	var incidents []model.Incident
+	db = h.DB(ctx)
+	db = db.Table("Incident n")
+	db = db.Select("n.*")
+	db = db.Joins(",Issue i")
+	db = db.Where("i.ID = n.IssueID")
+	db = db.Where("i.AnalysisID", analysis)
+	if excluded != nil {
+		db = db.Where("n.ID NOT IN (?)", excluded)
+	}
+	db = db.Order("n.IssueID,n.File,n.Line")
+	err = db.Find(&incidents).Error
+	if err != nil {
+		return
+	}
+	for i := range incidents {
+		m := &incidents[i]
+		issue, found := byID[m.IssueID]
+		if !found {
+			continue
+		}
+		n := len(issue.Locations)
+		if n == 0 || issue.Locations[n-1].File != m.File {
+			issue.Locations = append(
+				issue.Locations,
+				ReportFile{File: m.File})
+			n++
+		}
+		file := &issue.Locations[n-1]
+		incident := Incident{}
+		incident.With(m)
+		file.Incidents = append(file.Incidents, incident)
+		file.Effort += issue.Effort
+		issue.Incidents++
+		issue.Total += issue.Effort
+	}
+	return
+}
+
+//
+// archiveDeps returns the (report) dependencies within the analyses.
+// The analyses may be an ID, list of IDs or a (sub)query.
+func (h *AnalysisHandler) archiveDeps(ctx *gin.Context, analyses interface{}) (deps []DepReport, err error) {
+	var list []struct {
+		model.TechDependency
+		Applications int
+	}
+	db := h.DB(ctx)
+	db = db.Table("(?)", h.depReports(ctx, qf.Filter{}, analyses))
+	db = db.Order("Provider,Name,Version")
+	err = db.Find(&list).Error
+	if err != nil {
+		return
+	}
+	for i := range list {
+		m := &list[i]
+		r := DepReport{}
+		r.With(&m.TechDependency, m.Applications)
+		deps = append(deps, r)
+	}
+	return
+}
+
+//
+// appAnalysis returns the application analysis (with issues,
+// incidents and dependencies) with the specified ID.
+// When not specified, the latest analysis created before
+// the analysis (ID) specified by `before` is returned.
+func (h *AnalysisHandler) appAnalysis(ctx *gin.Context, appId uint, param string, before uint) (m *model.Analysis, err error) {
+	m = &model.Analysis{}
+	db := h.DB(ctx)
+	db = db.Preload("Issues.Incidents")
+	db = db.Preload("Dependencies")
+	db = db.Where("ApplicationID = ?", appId)
+	if param != "" {
+		id, pErr := strconv.Atoi(param)
+		if pErr != nil {
+			err = &BadRequestError{"analysis id must be a number."}
+			return
+		}
+		err = db.First(m, id).Error
+		return
+	}
+	if before > 0 {
+		db = db.Where("id < ?", before)
+	}
+	err = db.Last(m).Error
+	return
+}
+
+//
+// Analysis REST resource.
+type Analysis struct {
+	Resource     `yaml:",inline"`
 	Effort       int              `json:"effort"`
 	Issues       []Issue          `json:"issues,omitempty"`
 	Dependencies []TechDependency `json:"dependencies,omitempty"`
@@ -1718,6 +2314,39 @@ func (r *Analysis) With(m *model.Analysis) {
 	}
 }
 
+//
+// Waive removes waived issues, incidents and effort.
+// When included, the waived issues are flagged and the
+// incidents and effort are not adjusted.
+func (r *Analysis) Waive(waived *WaivedIssues, included bool) {
+	issues := []Issue{}
+	for i := range r.Issues {
+		issue := &r.Issues[i]
+		if waived.Waived(issue.ID) {
+			if !included {
+				continue
+			}
+			issue.Waived = true
+		}
+		if !included {
+			incidents := []Incident{}
+			for _, n := range issue.Incidents {
+				if !waived.IncidentWaived(issue.ID, n.ID) {
+					incidents = append(incidents, n)
+				}
+			}
+			if issue.Incidents != nil {
+				issue.Incidents = incidents
+			}
+		}
+		issues = append(issues, *issue)
+	}
+	r.Issues = issues
+	if !included {
+		r.Effort -= waived.Effort[r.ID]
+	}
+}
+
 //
 // Model builds a model.
 func (r *Analysis) Model() (m *model.Analysis) {
@@ -1754,6 +2383,7 @@ type Issue struct {
 	Links       []Link     `json:"links,omitempty" yaml:",omitempty"`
 	Facts       FactMap    `json:"facts,omitempty" yaml:",omitempty"`
 	Labels      []string   `json:"labels"`
+	Waived      bool       `json:"waived,omitempty" yaml:",omitempty"`
 }
 
 //
@@ -1850,12 +2480,13 @@ func (r *TechDependency) Model() (m *model.TechDependency) {
 //
 // Incident REST resource.
 type Incident struct {
-	Resource `yaml:",inline"`
-	File     string  `json:"file"`
-	Line     int     `json:"line"`
-	Message  string  `json:"message"`
-	CodeSnip string  `json:"codeSnip"`
-	Facts    FactMap `json:"facts"`
+	Resource    `yaml:",inline"`
+	File        string  `json:"file"`
+	Line        int     `json:"line"`
+	Message     string  `json:"message"`
+	CodeSnip    string  `json:"codeSnip"`
+	Facts       FactMap `json:"facts"`
+	Fingerprint string  `json:"fingerprint,omitempty" yaml:",omitempty"`
 }
 
 //
@@ -1869,6 +2500,7 @@ func (r *Incident) With(m *model.Incident) {
 	if m.Facts != nil {
 		_ = json.Unmarshal(m.Facts, &r.Facts)
 	}
+	r.Fingerprint = Fingerprint(m)
 }
 
 //
@@ -1904,6 +2536,24 @@ type RuleReport struct {
 	Applications int      `json:"applications"`
 }
 
+//
+// With updates the resource with the model.
+func (r *RuleReport) With(m *model.Issue, applications int) {
+	r.RuleSet = m.RuleSet
+	r.Rule = m.Rule
+	r.Name = m.Name
+	r.Description = m.Description
+	r.Category = m.Category
+	r.Effort = m.Effort
+	r.Applications = applications
+	if m.Labels != nil {
+		_ = json.Unmarshal(m.Labels, &r.Labels)
+	}
+	if m.Links != nil {
+		_ = json.Unmarshal(m.Links, &r.Links)
+	}
+}
+
 //
 // IssueReport REST resource.
 type IssueReport struct {
@@ -1919,6 +2569,25 @@ type IssueReport struct {
 	Files       int      `json:"files"`
 }
 
+//
+// With updates the resource with the model.
+func (r *IssueReport) With(m *model.Issue, files int) {
+	r.ID = m.ID
+	r.RuleSet = m.RuleSet
+	r.Rule = m.Rule
+	r.Name = m.Name
+	r.Description = m.Description
+	r.Category = m.Category
+	r.Effort = m.Effort
+	r.Files = files
+	if m.Labels != nil {
+		_ = json.Unmarshal(m.Labels, &r.Labels)
+	}
+	if m.Links != nil {
+		_ = json.Unmarshal(m.Links, &r.Links)
+	}
+}
+
 //
 // IssueAppReport REST resource.
 type IssueAppReport struct {
@@ -1958,6 +2627,19 @@ type DepReport struct {
 	Applications int      `json:"applications"`
 }
 
+//
+// With updates the resource with the model.
+func (r *DepReport) With(m *model.TechDependency, applications int) {
+	r.Provider = m.Provider
+	r.Name = m.Name
+	r.Version = m.Version
+	r.SHA = m.SHA
+	r.Applications = applications
+	if m.Labels != nil {
+		_ = json.Unmarshal(m.Labels, &r.Labels)
+	}
+}
+
 //
 // DepAppReport REST resource.
 type DepAppReport struct {
@@ -1979,3 +2661,246 @@ type DepAppReport struct {
 //
 // FactMap map.
 type FactMap map[string]interface{}
+
+//
+// AnalysisDiff REST resource.
+type AnalysisDiff struct {
+	From   uint       `json:"from"`
+	To     uint       `json:"to"`
+	Effort EffortDiff `json:"effort"`
+	Issues struct {
+		New       []IssueDiff `json:"new"`
+		Resolved  []IssueDiff `json:"resolved"`
+		Unchanged []IssueDiff `json:"unchanged"`
+	} `json:"issues"`
+	Dependencies struct {
+		Added    []TechDependency `json:"added"`
+		Removed  []TechDependency `json:"removed"`
+		Upgraded []DepDiff        `json:"upgraded"`
+	} `json:"dependencies"`
+}
+
+//
+// With updates the resource with the compared models.
+func (r *AnalysisDiff) With(from, to *model.Analysis) {
+	r.From = from.ID
+	r.To = to.ID
+	r.Effort = EffortDiff{From: from.Effort, To: to.Effort}
+	r.Issues.New = []IssueDiff{}
+	r.Issues.Resolved = []IssueDiff{}
+	r.Issues.Unchanged = []IssueDiff{}
+	fromIssues := make(map[string]*model.Issue)
+	for i := range from.Issues {
+		m := &from.Issues[i]
+		fromIssues[m.RuleSet+"/"+m.Rule] = m
+	}
+	toIssues := make(map[string]*model.Issue)
+	for i := range to.Issues {
+		m := &to.Issues[i]
+		key := m.RuleSet + "/" + m.Rule
+		toIssues[key] = m
+		d := IssueDiff{}
+		if prior, found := fromIssues[key]; found {
+			d.With(prior, m)
+			r.Issues.Unchanged = append(r.Issues.Unchanged, d)
+		} else {
+			d.With(&model.Issue{}, m)
+			r.Issues.New = append(r.Issues.New, d)
+		}
+	}
+	for i := range from.Issues {
+		m := &from.Issues[i]
+		if _, found := toIssues[m.RuleSet+"/"+m.Rule]; !found {
+			d := IssueDiff{}
+			d.With(m, &model.Issue{})
+			r.Issues.Resolved = append(r.Issues.Resolved, d)
+		}
+	}
+	r.Dependencies.Added = []TechDependency{}
+	r.Dependencies.Removed = []TechDependency{}
+	r.Dependencies.Upgraded = []DepDiff{}
+	fromDeps := r.depIndex(from.Dependencies)
+	toDeps := r.depIndex(to.Dependencies)
+	for i := range to.Dependencies {
+		m := &to.Dependencies[i]
+		key := m.Provider + "/" + m.Name
+		if prior, found := fromDeps[key][m.Version]; found {
+			if prior.SHA != m.SHA {
+				d := DepDiff{}
+				d.With(prior, m)
+				r.Dependencies.Upgraded = append(r.Dependencies.Upgraded, d)
+			}
+			continue
+		}
+		if prior := r.upgraded(fromDeps[key], toDeps[key]); prior != nil {
+			d := DepDiff{}
+			d.With(prior, m)
+			r.Dependencies.Upgraded = append(r.Dependencies.Upgraded, d)
+			continue
+		}
+		dep := TechDependency{}
+		dep.With(m)
+		r.Dependencies.Added = append(r.Dependencies.Added, dep)
+	}
+	for i := range from.Dependencies {
+		m := &from.Dependencies[i]
+		key := m.Provider + "/" + m.Name
+		if _, found := toDeps[key][m.Version]; found {
+			continue
+		}
+		if r.upgraded(fromDeps[key], toDeps[key]) != nil {
+			continue
+		}
+		dep := TechDependency{}
+		dep.With(m)
+		r.Dependencies.Removed = append(r.Dependencies.Removed, dep)
+	}
+}
+
+//
+// depIndex returns the dependencies indexed by
+// provider/name and then by version.
+func (r *AnalysisDiff) depIndex(deps []model.TechDependency) (index map[string]map[string]*model.TechDependency) {
+	index = make(map[string]map[string]*model.TechDependency)
+	for i := range deps {
+		m := &deps[i]
+		key := m.Provider + "/" + m.Name
+		versions, found := index[key]
+		if !found {
+			versions = make(map[string]*model.TechDependency)
+			index[key] = versions
+		}
+		versions[m.Version] = m
+	}
+	return
+}
+
+//
+// upgraded returns the prior version of a dependency (name)
+// upgraded between analyses. A dependency is upgraded when
+// exactly one version was replaced by exactly one (other)
+// version. Otherwise, the versions are added and removed.
+func (r *AnalysisDiff) upgraded(from, to map[string]*model.TechDependency) (prior *model.TechDependency) {
+	var removed, added []*model.TechDependency
+	for version, m := range from {
+		if _, found := to[version]; !found {
+			removed = append(removed, m)
+		}
+	}
+	for version, m := range to {
+		if _, found := from[version]; !found {
+			added = append(added, m)
+		}
+	}
+	if len(removed) == 1 && len(added) == 1 {
+		prior = removed[0]
+	}
+	return
+}
+
+//
+// IssueDiff REST resource.
+type IssueDiff struct {
+	RuleSet  string     `json:"ruleset"`
+	Rule     string     `json:"rule"`
+	Name     string     `json:"name"`
+	Category string     `json:"category"`
+	Effort   EffortDiff `json:"effort"`
+	Files    []FileDiff `json:"files"`
+}
+
+//
+// With updates the resource with the compared models.
+// Incidents are matched (within the file) by line and message.
+func (r *IssueDiff) With(from, to *model.Issue) {
+	m := to
+	if m.Rule == "" {
+		m = from
+	}
+	r.RuleSet = m.RuleSet
+	r.Rule = m.Rule
+	r.Name = m.Name
+	r.Category = m.Category
+	r.Effort = EffortDiff{From: from.Effort, To: to.Effort}
+	r.Files = []FileDiff{}
+	files := make(map[string]*FileDiff)
+	file := func(name string) (d *FileDiff) {
+		d, found := files[name]
+		if !found {
+			d = &FileDiff{File: name}
+			files[name] = d
+		}
+		return
+	}
+	key := func(m *model.Incident) string {
+		return m.File + ":" + strconv.Itoa(m.Line) + ":" + m.Message
+	}
+	unmatched := make(map[string]int)
+	for i := range from.Incidents {
+		unmatched[key(&from.Incidents[i])]++
+	}
+	for i := range to.Incidents {
+		m := &to.Incidents[i]
+		k := key(m)
+		if unmatched[k] > 0 {
+			unmatched[k]--
+			continue
+		}
+		file(m.File).Added++
+	}
+	for i := range from.Incidents {
+		m := &from.Incidents[i]
+		k := key(m)
+		if unmatched[k] > 0 {
+			unmatched[k]--
+			file(m.File).Removed++
+		}
+	}
+	for _, d := range files {
+		r.Files = append(r.Files, *d)
+	}
+	sort.Slice(
+		r.Files,
+		func(i, j int) bool {
+			return r.Files[i].File < r.Files[j].File
+		})
+}
+
+//
+// EffortDiff REST resource.
+type EffortDiff struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+//
+// FileDiff REST resource.
+// Incidents added and removed within the file.
+type FileDiff struct {
+	File    string `json:"file"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+//
+// DepDiff REST resource.
+// Dependency version (or SHA) changed.
+type DepDiff struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	FromSHA  string `json:"fromSha,omitempty"`
+	ToSHA    string `json:"toSha,omitempty"`
+}
+
+//
+// With updates the resource with the compared models.
+func (r *DepDiff) With(from, to *model.TechDependency) {
+	r.Provider = to.Provider
+	r.Name = to.Name
+	r.From = from.Version
+	r.To = to.Version
+	r.FromSHA = from.SHA
+	r.ToSHA = to.SHA
+}
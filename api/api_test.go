@@ -1,10 +1,34 @@
 package api
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/konveyor/tackle2-hub/database"
+	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
+	"github.com/konveyor/tackle2-hub/migration"
+	"github.com/konveyor/tackle2-hub/model"
+	tasking "github.com/konveyor/tackle2-hub/task"
 	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAccepted(t *testing.T) {
@@ -72,3 +96,821 @@ func TestFactKey(t *testing.T) {
 	g.Expect(key.Source()).To(gomega.Equal("test"))
 	g.Expect(key.Name()).To(gomega.Equal(""))
 }
+
+func TestSortLogs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	h := TaskHandler{}
+	names := []string{"main.10.log", "main.2.log", "main.0.log", "main.1.log"}
+	h.sortLogs(names)
+	g.Expect(names).To(gomega.Equal([]string{"main.0.log", "main.1.log", "main.2.log", "main.10.log"}))
+}
+
+func TestTaskGroupStatus(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	started := time.Now().Add(-time.Hour)
+	terminated := time.Now()
+	m := &model.TaskGroup{State: tasking.Ready}
+	m.Tasks = []model.Task{
+		{State: tasking.Succeeded, Started: &started, Terminated: &terminated},
+		{State: tasking.Running, Report: &model.TaskReport{Total: 4, Completed: 2}},
+		{State: tasking.Ready},
+		{State: tasking.Ready},
+	}
+	status := TaskGroupStatus{}
+	status.With(m, nil)
+	g.Expect(status.State).To(gomega.Equal(tasking.Running))
+	g.Expect(status.Tasks[tasking.Ready]).To(gomega.Equal(2))
+	g.Expect(status.Completed).To(gomega.Equal(37))
+	g.Expect(status.Started).To(gomega.Equal(&started))
+	g.Expect(status.Terminated).To(gomega.BeNil())
+	// Terminal.
+	for i := range m.Tasks {
+		m.Tasks[i].State = tasking.Succeeded
+	}
+	m.Tasks[3].State = tasking.Failed
+	status = TaskGroupStatus{}
+	status.With(m, nil)
+	g.Expect(status.State).To(gomega.Equal(tasking.Failed))
+	g.Expect(status.Completed).To(gomega.Equal(100))
+	g.Expect(status.Terminated).To(gomega.Equal(&terminated))
+	// Partially canceled.
+	m.Tasks[3].State = tasking.Canceled
+	status = TaskGroupStatus{}
+	status.With(m, nil)
+	g.Expect(status.State).To(gomega.Equal(tasking.Succeeded))
+	// Canceled.
+	for i := range m.Tasks {
+		m.Tasks[i].State = tasking.Canceled
+	}
+	status = TaskGroupStatus{}
+	status.With(m, nil)
+	g.Expect(status.State).To(gomega.Equal(tasking.Canceled))
+}
+
+func TestTaskQueue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	for i, state := range []string{
+		tasking.Running,
+		tasking.Ready,
+		tasking.Postponed,
+		tasking.Succeeded,
+		tasking.Ready,
+	} {
+		task := &model.Task{Name: "t", Addon: "analyzer", State: state, Priority: i}
+		err := db.Create(task).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	w := request(http.MethodGet, "/tasks/queue", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	var list []TaskQueued
+	err := json.Unmarshal(w.Body.Bytes(), &list)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(list)).To(gomega.Equal(3))
+	g.Expect(list[0].ID).To(gomega.Equal(uint(5)))
+	g.Expect(list[0].Position).To(gomega.Equal(1))
+	g.Expect(list[0].Effective).To(gomega.Equal(4))
+	g.Expect(list[1].ID).To(gomega.Equal(uint(3)))
+	g.Expect(list[2].ID).To(gomega.Equal(uint(2)))
+	g.Expect(list[2].Position).To(gomega.Equal(3))
+}
+
+func TestTaskSubmitted(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	// Matched.
+	w := request(http.MethodPost, TasksRoot, `{"name":"a","kind":"analyzer","state":"Ready","data":{}}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusCreated))
+	task := Task{}
+	_ = json.Unmarshal(w.Body.Bytes(), &task)
+	g.Expect(task.Addon).To(gomega.Equal("analyzer"))
+	// Not matched.
+	w = request(http.MethodPost, TasksRoot, `{"name":"b","kind":"other","state":"Ready","data":{}}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	g.Expect(w.Body.String()).To(gomega.ContainSubstring("not matched"))
+	w = request(http.MethodPost, TasksRoot, `{"name":"b","kind":"other","data":{}}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusCreated))
+	_ = json.Unmarshal(w.Body.Bytes(), &task)
+	w = request(http.MethodPut, fmt.Sprintf("/tasks/%d/submit", task.ID), "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	g.Expect(w.Body.String()).To(gomega.ContainSubstring("not matched"))
+	m := &model.Task{}
+	err := db.First(m, task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.State).To(gomega.Equal(tasking.Created))
+	m.State = tasking.Failed
+	err = db.Save(m).Error
+	g.Expect(err).To(gomega.BeNil())
+	w = request(http.MethodPost, fmt.Sprintf("/tasks/%d/rerun", m.ID), "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	g.Expect(w.Body.String()).To(gomega.ContainSubstring("not matched"))
+}
+
+func TestTaskBulk(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	running := &model.Task{Name: "running", Addon: "analyzer", State: tasking.Running}
+	succeeded := &model.Task{Name: "succeeded", Addon: "analyzer", State: tasking.Succeeded}
+	failed := &model.Task{Name: "failed", Kind: "analyzer", Addon: "old", State: tasking.Failed, Retries: 2}
+	other := &model.Task{Name: "other", Kind: "other", State: tasking.Failed}
+	for _, m := range []*model.Task{running, succeeded, failed, other} {
+		err := db.Create(m).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Filter required.
+	w := request(http.MethodPut, TasksBulkCancelRoot, "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	w = request(http.MethodPut, TasksBulkRetryRoot, "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	w = request(http.MethodDelete, TasksBulkRoot, "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	var count int64
+	db.Model(&model.Task{}).Count(&count)
+	g.Expect(count).To(gomega.Equal(int64(4)))
+	// Cancel.
+	filter := fmt.Sprintf("?filter=id:(%d|%d)", running.ID, succeeded.ID)
+	errors := bulkResults(g, request(http.MethodPut, TasksBulkCancelRoot+filter, ""))
+	g.Expect(len(errors)).To(gomega.Equal(2))
+	g.Expect(errors[running.ID]).To(gomega.BeEmpty())
+	g.Expect(errors[succeeded.ID]).ToNot(gomega.BeEmpty())
+	m := &model.Task{}
+	err := db.First(m, running.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Canceled).To(gomega.BeTrue())
+	// Retry.
+	errors = bulkResults(g, request(http.MethodPut, TasksBulkRetryRoot+"?filter=state:Failed", ""))
+	g.Expect(len(errors)).To(gomega.Equal(2))
+	g.Expect(errors[failed.ID]).To(gomega.BeEmpty())
+	g.Expect(errors[other.ID]).To(gomega.ContainSubstring("not matched"))
+	m = &model.Task{}
+	err = db.First(m, failed.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.State).To(gomega.Equal(tasking.Ready))
+	g.Expect(m.Addon).To(gomega.Equal("analyzer"))
+	g.Expect(m.Retries).To(gomega.Equal(0))
+	m = &model.Task{}
+	err = db.First(m, other.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.State).To(gomega.Equal(tasking.Failed))
+	// Delete.
+	filter = fmt.Sprintf("?filter=id:%d", succeeded.ID)
+	errors = bulkResults(g, request(http.MethodDelete, TasksBulkRoot+filter, ""))
+	g.Expect(errors[succeeded.ID]).To(gomega.BeEmpty())
+	db.Model(&model.Task{}).Count(&count)
+	g.Expect(count).To(gomega.Equal(int64(3)))
+}
+
+func TestTaskGroupBulk(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	group := &model.TaskGroup{
+		Name:  "group",
+		State: tasking.Ready,
+		Tasks: []model.Task{
+			{Name: "running", Addon: "analyzer", State: tasking.Running},
+			{Name: "succeeded", Addon: "analyzer", State: tasking.Succeeded},
+			{Name: "failed", Kind: "analyzer", State: tasking.Failed},
+			{Name: "canceled", Kind: "other", State: tasking.Canceled},
+		},
+	}
+	err := db.Create(group).Error
+	g.Expect(err).To(gomega.BeNil())
+	// Cancel.
+	errors := bulkResults(g, request(http.MethodPut, fmt.Sprintf("/taskgroups/%d/cancel", group.ID), ""))
+	g.Expect(len(errors)).To(gomega.Equal(1))
+	g.Expect(errors[group.Tasks[0].ID]).To(gomega.BeEmpty())
+	m := &model.Task{}
+	err = db.First(m, group.Tasks[0].ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Canceled).To(gomega.BeTrue())
+	// Resubmit.
+	errors = bulkResults(g, request(http.MethodPut, fmt.Sprintf("/taskgroups/%d/resubmit", group.ID), ""))
+	g.Expect(len(errors)).To(gomega.Equal(2))
+	g.Expect(errors[group.Tasks[2].ID]).To(gomega.BeEmpty())
+	g.Expect(errors[group.Tasks[3].ID]).To(gomega.ContainSubstring("not matched"))
+	m = &model.Task{}
+	err = db.First(m, group.Tasks[2].ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.State).To(gomega.Equal(tasking.Ready))
+	g.Expect(m.Addon).To(gomega.Equal("analyzer"))
+	// Not found.
+	w := request(http.MethodPut, "/taskgroups/0/resubmit", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusNotFound))
+}
+
+func TestTaskRerun(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	app := &model.Application{Name: "a"}
+	err := db.Create(app).Error
+	g.Expect(err).To(gomega.BeNil())
+	origin := &model.Task{
+		Name:          "origin",
+		Kind:          "analyzer",
+		Addon:         "old",
+		Data:          []byte(`{"mode":"full"}`),
+		Variant:       "v",
+		Policy:        "isolated",
+		Priority:      5,
+		Deadline:      60,
+		RetryPolicy:   []byte(`{"limit":0}`),
+		ApplicationID: &app.ID,
+		State:         tasking.Running,
+	}
+	err = db.Create(origin).Error
+	g.Expect(err).To(gomega.BeNil())
+	path := fmt.Sprintf("/tasks/%d/rerun", origin.ID)
+	// Not terminated.
+	w := request(http.MethodPost, path, "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusConflict))
+	origin.State = tasking.Failed
+	err = db.Save(origin).Error
+	g.Expect(err).To(gomega.BeNil())
+	// Copied.
+	w = request(http.MethodPost, path, "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusCreated))
+	task := Task{}
+	_ = json.Unmarshal(w.Body.Bytes(), &task)
+	m := &model.Task{}
+	err = db.First(m, task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(m.Name).To(gomega.Equal(origin.Name))
+	g.Expect(m.Kind).To(gomega.Equal(origin.Kind))
+	g.Expect(m.Addon).To(gomega.Equal("analyzer"))
+	g.Expect(m.Variant).To(gomega.Equal(origin.Variant))
+	g.Expect(m.Policy).To(gomega.Equal(origin.Policy))
+	g.Expect(m.Priority).To(gomega.Equal(origin.Priority))
+	g.Expect(m.Deadline).To(gomega.Equal(origin.Deadline))
+	g.Expect(string(m.RetryPolicy)).To(gomega.Equal(`{"limit":0}`))
+	g.Expect(*m.ApplicationID).To(gomega.Equal(app.ID))
+	g.Expect(string(m.Data)).To(gomega.Equal(`{"mode":"full"}`))
+	g.Expect(m.State).To(gomega.Equal(tasking.Ready))
+	g.Expect(*m.OriginID).To(gomega.Equal(origin.ID))
+	// Data override.
+	w = request(http.MethodPost, path, `{"data":{"mode":"quick"},"state":"Created"}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusCreated))
+	_ = json.Unmarshal(w.Body.Bytes(), &task)
+	m = &model.Task{}
+	err = db.First(m, task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(string(m.Data)).To(gomega.Equal(`{"mode":"quick"}`))
+	g.Expect(m.State).To(gomega.Equal(tasking.Created))
+	g.Expect(*m.OriginID).To(gomega.Equal(origin.ID))
+	// State not valid.
+	w = request(http.MethodPost, path, `{"state":"Running"}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	// Not found.
+	w = request(http.MethodPost, "/tasks/0/rerun", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusNotFound))
+}
+
+//
+// bulkResults returns the errors (by task ID) reported
+// by a bulk operation.
+func bulkResults(g *gomega.WithT, w *httptest.ResponseRecorder) (errors map[uint]string) {
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	var list []TaskBulkResult
+	err := json.Unmarshal(w.Body.Bytes(), &list)
+	g.Expect(err).To(gomega.BeNil())
+	errors = make(map[uint]string)
+	for _, r := range list {
+		errors[r.ID] = r.Error
+	}
+	return
+}
+
+//
+// testRouter returns the DB and a function used to send
+// requests to the task and analysis routes. The (fake)
+// cluster has an analyzer addon.
+func testRouter(g *gomega.WithT) (db *gorm.DB, request func(method, path, body string) *httptest.ResponseRecorder) {
+	Settings.DB.Path = "/tmp/api.db"
+	Settings.Bucket.Path = "/tmp/api/bucket"
+	_ = os.Remove(Settings.DB.Path)
+	err := migration.Migrate(migration.All())
+	g.Expect(err).To(gomega.BeNil())
+	db, err = database.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	scheme := runtime.NewScheme()
+	err = crd.SchemeBuilder.AddToScheme(scheme)
+	g.Expect(err).To(gomega.BeNil())
+	addon := &crd.Addon{}
+	addon.Namespace = Settings.Hub.Namespace
+	addon.Name = "analyzer"
+	addon.Spec.Kind = "analyzer"
+	client := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(addon).
+		Build()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Render(), ErrorHandler())
+	router.Use(func(ctx *gin.Context) {
+		rtx := WithContext(ctx)
+		rtx.DB = db
+		rtx.Client = client
+	})
+	TaskHandler{}.AddRoutes(router)
+	TaskGroupHandler{}.AddRoutes(router)
+	AnalysisHandler{}.AddRoutes(router)
+	request = func(method, path, body string) (w *httptest.ResponseRecorder) {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		r := httptest.NewRequest(method, path, reader)
+		if body != "" {
+			r.Header.Set(ContentType, binding.MIMEJSON)
+		}
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return
+	}
+	return
+}
+
+func TestAnalysisDiff(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	from := &model.Analysis{Effort: 10}
+	from.ID = 1
+	from.Issues = []model.Issue{
+		{
+			RuleSet: "A",
+			Rule:    "r1",
+			Effort:  5,
+			Incidents: []model.Incident{
+				{File: "a.java", Line: 1, Message: "m"},
+				{File: "a.java", Line: 2, Message: "m"},
+			},
+		},
+		{RuleSet: "A", Rule: "r2", Effort: 5},
+	}
+	from.Dependencies = []model.TechDependency{
+		{Provider: "java", Name: "log4j", Version: "1.0"},
+		{Provider: "java", Name: "junit", Version: "4.0"},
+	}
+	to := &model.Analysis{Effort: 8}
+	to.ID = 2
+	to.Issues = []model.Issue{
+		{
+			RuleSet: "A",
+			Rule:    "r1",
+			Effort:  3,
+			Incidents: []model.Incident{
+				{File: "a.java", Line: 1, Message: "m"},
+				{File: "b.java", Line: 1, Message: "m"},
+			},
+		},
+		{RuleSet: "A", Rule: "r3", Effort: 5},
+	}
+	to.Dependencies = []model.TechDependency{
+		{Provider: "java", Name: "log4j", Version: "2.0"},
+		{Provider: "java", Name: "gson", Version: "1.0"},
+	}
+	diff := AnalysisDiff{}
+	diff.With(from, to)
+	g.Expect(diff.Effort).To(gomega.Equal(EffortDiff{From: 10, To: 8}))
+	g.Expect(len(diff.Issues.New)).To(gomega.Equal(1))
+	g.Expect(diff.Issues.New[0].Rule).To(gomega.Equal("r3"))
+	g.Expect(len(diff.Issues.Resolved)).To(gomega.Equal(1))
+	g.Expect(diff.Issues.Resolved[0].Rule).To(gomega.Equal("r2"))
+	g.Expect(len(diff.Issues.Unchanged)).To(gomega.Equal(1))
+	unchanged := diff.Issues.Unchanged[0]
+	g.Expect(unchanged.Effort).To(gomega.Equal(EffortDiff{From: 5, To: 3}))
+	g.Expect(unchanged.Files).To(
+		gomega.Equal([]FileDiff{
+			{File: "a.java", Removed: 1},
+			{File: "b.java", Added: 1},
+		}))
+	g.Expect(len(diff.Dependencies.Added)).To(gomega.Equal(1))
+	g.Expect(diff.Dependencies.Added[0].Name).To(gomega.Equal("gson"))
+	g.Expect(len(diff.Dependencies.Removed)).To(gomega.Equal(1))
+	g.Expect(diff.Dependencies.Removed[0].Name).To(gomega.Equal("junit"))
+	g.Expect(diff.Dependencies.Upgraded).To(
+		gomega.Equal([]DepDiff{
+			{Provider: "java", Name: "log4j", From: "1.0", To: "2.0"},
+		}))
+	// Rebuilt (SHA) and multiple versions.
+	from.Dependencies = []model.TechDependency{
+		{Provider: "java", Name: "log4j", Version: "1.0", SHA: "a"},
+		{Provider: "java", Name: "guava", Version: "1.0"},
+		{Provider: "java", Name: "guava", Version: "2.0"},
+	}
+	to.Dependencies = []model.TechDependency{
+		{Provider: "java", Name: "log4j", Version: "1.0", SHA: "b"},
+		{Provider: "java", Name: "guava", Version: "2.0"},
+		{Provider: "java", Name: "guava", Version: "3.0"},
+		{Provider: "java", Name: "guava", Version: "4.0"},
+	}
+	diff = AnalysisDiff{}
+	diff.With(from, to)
+	g.Expect(diff.Dependencies.Upgraded).To(
+		gomega.Equal([]DepDiff{
+			{Provider: "java", Name: "log4j", From: "1.0", To: "1.0", FromSHA: "a", ToSHA: "b"},
+		}))
+	g.Expect(len(diff.Dependencies.Added)).To(gomega.Equal(2))
+	g.Expect(diff.Dependencies.Added[0].Version).To(gomega.Equal("3.0"))
+	g.Expect(diff.Dependencies.Added[1].Version).To(gomega.Equal("4.0"))
+	g.Expect(len(diff.Dependencies.Removed)).To(gomega.Equal(1))
+	g.Expect(diff.Dependencies.Removed[0].Version).To(gomega.Equal("1.0"))
+	// Multiple versions upgraded.
+	to.Dependencies[2].Version = "1.5"
+	to.Dependencies = to.Dependencies[:3]
+	diff = AnalysisDiff{}
+	diff.With(from, to)
+	g.Expect(len(diff.Dependencies.Added)).To(gomega.Equal(0))
+	g.Expect(len(diff.Dependencies.Removed)).To(gomega.Equal(0))
+	g.Expect(diff.Dependencies.Upgraded[1]).To(
+		gomega.Equal(DepDiff{Provider: "java", Name: "guava", From: "1.0", To: "1.5"}))
+}
+
+func TestWaived(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.DB.Path = "/tmp/api.db"
+	Settings.Bucket.Path = "/tmp/api/bucket"
+	_ = os.Remove(Settings.DB.Path)
+	err := migration.Migrate(migration.All())
+	g.Expect(err).To(gomega.BeNil())
+	db, err := database.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	app := &model.Application{Name: "a"}
+	err = db.Create(app).Error
+	g.Expect(err).To(gomega.BeNil())
+	analysis := &model.Analysis{ApplicationID: app.ID, Effort: 10}
+	analysis.Issues = []model.Issue{
+		{
+			RuleSet:  "A",
+			Rule:     "r1",
+			Category: "mandatory",
+			Effort:   1,
+			Incidents: []model.Incident{
+				{File: "src/a.java", Message: "m"},
+				{File: "src/test/b.java", Message: "m"},
+			},
+		},
+		{
+			RuleSet:  "A",
+			Rule:     "r2",
+			Category: "mandatory",
+			Effort:   2,
+			Incidents: []model.Incident{
+				{File: "src/a.java", Message: "m"},
+			},
+		},
+		{
+			RuleSet:  "A",
+			Rule:     "r3",
+			Category: "mandatory",
+			Effort:   3,
+			Incidents: []model.Incident{
+				{File: "src/a.java", Message: "m"},
+			},
+		},
+	}
+	err = db.Create(analysis).Error
+	g.Expect(err).To(gomega.BeNil())
+	expired := time.Now().Add(-time.Hour)
+	waivers := []model.Waiver{
+		{RuleSet: "A", Rule: "r1", File: "src/test/*", Justification: "test code."},
+		{RuleSet: "A", Rule: "r2", ApplicationID: &app.ID, Justification: "false positive."},
+		{RuleSet: "A", Rule: "r3", Justification: "expired.", Expiration: &expired},
+	}
+	err = db.Create(&waivers).Error
+	g.Expect(err).To(gomega.BeNil())
+	waived := WaivedIssues{}
+	err = waived.With(db, analysis.ID)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(waived.Waived(analysis.Issues[0].ID)).To(gomega.BeFalse())
+	g.Expect(waived.Waived(analysis.Issues[1].ID)).To(gomega.BeTrue())
+	g.Expect(waived.Waived(analysis.Issues[2].ID)).To(gomega.BeFalse())
+	var count int64
+	err = db.Table("(?)", waived.Issues()).Count(&count).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	err = db.Table("(?)", waived.Incidents()).Count(&count).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(2)))
+	g.Expect(waived.Effort[analysis.ID]).To(gomega.Equal(3))
+	r := Analysis{}
+	r.With(analysis)
+	r.Waive(&waived, false)
+	g.Expect(r.Effort).To(gomega.Equal(7))
+	g.Expect(len(r.Issues)).To(gomega.Equal(2))
+	g.Expect(len(r.Issues[0].Incidents)).To(gomega.Equal(1))
+	// Fingerprint.
+	incident := &analysis.Issues[0].Incidents[0]
+	err = db.Model(&waivers[0]).Update("Fingerprint", Fingerprint(incident)).Error
+	g.Expect(err).To(gomega.BeNil())
+	err = db.Model(&waivers[0]).Update("File", "").Error
+	g.Expect(err).To(gomega.BeNil())
+	waived = WaivedIssues{}
+	err = waived.With(db, analysis.ID)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(waived.IncidentWaived(incident.IssueID, incident.ID)).To(gomega.BeTrue())
+	g.Expect(waived.Effort[analysis.ID]).To(gomega.Equal(3))
+	// All incidents matched.
+	err = db.Model(&waivers[0]).Updates(map[string]interface{}{"Fingerprint": "", "File": "*.java"}).Error
+	g.Expect(err).To(gomega.BeNil())
+	waived = WaivedIssues{}
+	err = waived.With(db, analysis.ID)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(waived.Waived(analysis.Issues[0].ID)).To(gomega.BeTrue())
+	err = db.Table("(?)", waived.Issues()).Count(&count).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(2)))
+	err = db.Table("(?)", waived.Incidents()).Count(&count).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(count).To(gomega.Equal(int64(3)))
+	g.Expect(waived.Effort[analysis.ID]).To(gomega.Equal(4))
+}
+
+func TestSarif(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	analysis := model.Analysis{Effort: 3}
+	analysis.ID = 4
+	analysis.Application = &model.Application{Name: "app"}
+	analysis.Issues = []model.Issue{
+		{
+			RuleSet:  "A",
+			Rule:     "r1",
+			Name:     "Rule 1",
+			Category: "mandatory",
+			Effort:   1,
+			Links:    []byte(`[{"url":"https://a.com"}]`),
+			Labels:   []byte(`["konveyor.io/target=quarkus"]`),
+			Incidents: []model.Incident{
+				{File: "a.java", Line: 10, Message: "m", CodeSnip: "x"},
+				{File: "b.java", Message: "m"},
+				{File: "c.java", Message: "m", CodeSnip: "y"},
+			},
+		},
+	}
+	analysis.Issues[0].Incidents[1].ID = 7
+	waived := WaivedIssues{incidents: map[uint]bool{7: true}}
+	b := bytes.Buffer{}
+	writer := SarifWriter{}
+	err := writer.Begin(&b)
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 2; i++ {
+		run := SarifRun{}
+		run.With(&analysis, &waived)
+		err = writer.Write(&run)
+		g.Expect(err).To(gomega.BeNil())
+	}
+	err = writer.End()
+	g.Expect(err).To(gomega.BeNil())
+	r := Sarif{}
+	err = json.Unmarshal(b.Bytes(), &r)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(r.Schema).To(gomega.Equal(SarifSchema))
+	g.Expect(r.Version).To(gomega.Equal(SarifVersion))
+	g.Expect(len(r.Runs)).To(gomega.Equal(2))
+	run := r.Runs[0]
+	g.Expect(run.AutomationDetails.ID).To(gomega.Equal("app/4"))
+	g.Expect(len(run.Tool.Driver.Rules)).To(gomega.Equal(1))
+	rule := run.Tool.Driver.Rules[0]
+	g.Expect(rule.ID).To(gomega.Equal("A/r1"))
+	g.Expect(rule.HelpURI).To(gomega.Equal("https://a.com"))
+	g.Expect(rule.Properties["tags"]).To(gomega.Equal([]interface{}{"konveyor.io/target=quarkus"}))
+	g.Expect(len(run.Results)).To(gomega.Equal(3))
+	result := run.Results[0]
+	g.Expect(result.Level).To(gomega.Equal("error"))
+	g.Expect(result.Locations[0].Physical.Artifact.URI).To(gomega.Equal("a.java"))
+	g.Expect(result.Locations[0].Physical.Region.StartLine).To(gomega.Equal(10))
+	g.Expect(result.Locations[0].Physical.Region.Snippet.Text).To(gomega.Equal("x"))
+	g.Expect(result.Suppressions).To(gomega.BeNil())
+	result = run.Results[1]
+	g.Expect(result.Locations[0].Physical.Region).To(gomega.BeNil())
+	g.Expect(len(result.Suppressions)).To(gomega.Equal(1))
+	// Snippet without a line.
+	result = run.Results[2]
+	g.Expect(result.Locations[0].Physical.Region).To(gomega.BeNil())
+	g.Expect(result.Properties["snippet"]).To(gomega.Equal("y"))
+}
+
+func TestSarifFilter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	app := &model.Application{Name: "a"}
+	err := db.Create(app).Error
+	g.Expect(err).To(gomega.BeNil())
+	for i := 0; i < 2; i++ {
+		analysis := &model.Analysis{ApplicationID: app.ID}
+		err = db.Create(analysis).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	w := request(http.MethodGet, "/analyses/sarif", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	w = request(http.MethodGet, "/analyses/sarif?filter=application.id="+strconv.Itoa(int(app.ID)), "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	g.Expect(w.Header().Get(ContentType)).To(gomega.Equal(MIMESARIF))
+	r := Sarif{}
+	err = json.Unmarshal(w.Body.Bytes(), &r)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(r.Runs)).To(gomega.Equal(1))
+	g.Expect(r.Runs[0].AutomationDetails.ID).To(gomega.Equal("a/2"))
+}
+
+func TestReportArchive(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	r := ReportArchive{Created: time.Now()}
+	err := r.Open()
+	g.Expect(err).To(gomega.BeNil())
+	defer r.Close()
+	r.Rules = []RuleReport{
+		{RuleSet: "A", Rule: "r1", Category: "mandatory", Applications: 1},
+	}
+	issue := ReportIssue{Incidents: 2, Total: 2}
+	issue.RuleSet = "A"
+	issue.Rule = "r1"
+	issue.Category = "mandatory"
+	issue.Effort = 1
+	issue.Files = 1
+	issue.Locations = []ReportFile{
+		{
+			File:   "a.java",
+			Effort: 2,
+			Incidents: []Incident{
+				{File: "a.java", Line: 1, Message: "m", CodeSnip: "<x>"},
+				{File: "a.java", Line: 2, Message: "m,n"},
+			},
+		},
+	}
+	app := ReportApp{Analysis: 4, Effort: 2}
+	app.Name = "app"
+	app.Issues = []ReportIssue{issue}
+	app.Dependencies = []DepReport{{Provider: "java", Name: "d", Version: "1"}}
+	err = r.Add(&app)
+	g.Expect(err).To(gomega.BeNil())
+	var b bytes.Buffer
+	err = r.Write(&b)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(r.Effort).To(gomega.Equal(2))
+	g.Expect(r.Applications[0].Incidents).To(gomega.Equal(2))
+	g.Expect(r.Categories).To(gomega.Equal(
+		[]ReportCategory{{Name: "mandatory", Rules: 1, Incidents: 2, Effort: 2}}))
+	zipReader, err := gzip.NewReader(&b)
+	g.Expect(err).To(gomega.BeNil())
+	tarReader := tar.NewReader(zipReader)
+	files := make(map[string]string)
+	for {
+		header, nErr := tarReader.Next()
+		if nErr == io.EOF {
+			break
+		}
+		g.Expect(nErr).To(gomega.BeNil())
+		content, _ := io.ReadAll(tarReader)
+		files[path.Base(header.Name)] = string(content)
+	}
+	g.Expect(len(files)).To(gomega.Equal(4))
+	g.Expect(files["index.html"]).To(gomega.ContainSubstring("<pre>&lt;x&gt;</pre>"))
+	rows, err := csv.NewReader(strings.NewReader(files["incidents.csv"])).ReadAll()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(rows)).To(gomega.Equal(3))
+	g.Expect(rows[2][5]).To(gomega.Equal("m,n"))
+	rows, err = csv.NewReader(strings.NewReader(files["dependencies.csv"])).ReadAll()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(rows[1]).To(gomega.Equal([]string{"app", "java", "d", "1", "", ""}))
+}
+
+func TestAppReportArchive(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db, request := testRouter(g)
+	app := &model.Application{Name: "a"}
+	err := db.Create(app).Error
+	g.Expect(err).To(gomega.BeNil())
+	analysis := &model.Analysis{ApplicationID: app.ID, Effort: 5}
+	analysis.Issues = []model.Issue{
+		{
+			RuleSet:  "A",
+			Rule:     "r1",
+			Category: "mandatory",
+			Effort:   1,
+			Incidents: []model.Incident{
+				{File: "b.java", Line: 2, Message: "m"},
+				{File: "a.java", Line: 2, Message: "m"},
+				{File: "a.java", Line: 1, Message: "m"},
+			},
+		},
+		{
+			RuleSet:  "A",
+			Rule:     "r2",
+			Category: "optional",
+			Effort:   2,
+			Incidents: []model.Incident{
+				{File: "a.java", Line: 3, Message: "m"},
+			},
+		},
+	}
+	err = db.Create(analysis).Error
+	g.Expect(err).To(gomega.BeNil())
+	waiver := &model.Waiver{RuleSet: "A", Rule: "r1", File: "b.java", Justification: "j."}
+	err = db.Create(waiver).Error
+	g.Expect(err).To(gomega.BeNil())
+	w := request(http.MethodGet, "/applications/"+strconv.Itoa(int(app.ID))+"/analysis/report", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+	g.Expect(w.Header().Get(ContentType)).To(gomega.Equal(MIMEGZIP))
+	zipReader, err := gzip.NewReader(w.Body)
+	g.Expect(err).To(gomega.BeNil())
+	tarReader := tar.NewReader(zipReader)
+	files := make(map[string]string)
+	for {
+		header, nErr := tarReader.Next()
+		if nErr == io.EOF {
+			break
+		}
+		g.Expect(nErr).To(gomega.BeNil())
+		content, _ := io.ReadAll(tarReader)
+		files[path.Base(header.Name)] = string(content)
+	}
+	rows, err := csv.NewReader(strings.NewReader(files["issues.csv"])).ReadAll()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(rows)).To(gomega.Equal(3))
+	g.Expect(rows[1][2]).To(gomega.Equal("r1"))
+	g.Expect(rows[1][6]).To(gomega.Equal("1"))
+	g.Expect(rows[1][7]).To(gomega.Equal("2"))
+	rows, err = csv.NewReader(strings.NewReader(files["incidents.csv"])).ReadAll()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(rows)).To(gomega.Equal(4))
+	g.Expect(rows[1][3:5]).To(gomega.Equal([]string{"a.java", "1"}))
+	g.Expect(rows[2][3:5]).To(gomega.Equal([]string{"a.java", "2"}))
+	g.Expect(rows[3][2:5]).To(gomega.Equal([]string{"r2", "a.java", "3"}))
+	// Filter required.
+	w = request(http.MethodGet, "/analyses/report/archive", "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	w = request(http.MethodGet, "/analyses/report/archive?filter=application.id="+strconv.Itoa(int(app.ID)), "")
+	g.Expect(w.Code).To(gomega.Equal(http.StatusOK))
+}
+
+func TestAppCreate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.DB.Path = "/tmp/api.db"
+	Settings.Bucket.Path = "/tmp/api/bucket"
+	_ = os.Remove(Settings.DB.Path)
+	err := migration.Migrate(migration.All())
+	g.Expect(err).To(gomega.BeNil())
+	db, err := database.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	app := &model.Application{Name: "a"}
+	err = db.Create(app).Error
+	g.Expect(err).To(gomega.BeNil())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Render(), ErrorHandler())
+	router.Use(func(ctx *gin.Context) {
+		WithContext(ctx).DB = db
+	})
+	h := AnalysisHandler{}
+	router.POST(AppAnalysesRoot, Transaction, h.AppCreate)
+	post := func(issues string) (w *httptest.ResponseRecorder) {
+		var b bytes.Buffer
+		mp := multipart.NewWriter(&b)
+		documents := map[string]string{
+			FileField:  "{}",
+			IssueField: issues,
+			DepField:   `{"name":"d"}`,
+		}
+		for field, content := range documents {
+			header := textproto.MIMEHeader{}
+			header.Set(
+				"Content-Disposition",
+				fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, field))
+			header.Set(ContentType, binding.MIMEJSON)
+			part, _ := mp.CreatePart(header)
+			_, _ = part.Write([]byte(content))
+		}
+		_ = mp.Close()
+		request := httptest.NewRequest(http.MethodPost, "/applications/1/analyses", &b)
+		request.Header.Set(ContentType, mp.FormDataContentType())
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return
+	}
+	// Created.
+	w := post(`
+{"ruleset":"A","rule":"r1","effort":2,"incidents":[{"file":"a"},{"file":"b"}]}
+{"ruleset":"A","rule":"r2","incidents":[{"file":"c"}]}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusCreated))
+	analysis := &model.Analysis{}
+	err = db.Preload("Issues.Incidents").First(analysis).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(analysis.Effort).To(gomega.Equal(4))
+	g.Expect(len(analysis.Issues)).To(gomega.Equal(2))
+	g.Expect(len(analysis.Issues[0].Incidents)).To(gomega.Equal(2))
+	// Not valid (atomic).
+	w = post(`
+{"ruleset":"A","rule":"r1","incidents":[{"file":"a"}]}
+{"ruleset":"A","rule":"r2","incidents":[{"file":"c","line":"x"}]}`)
+	g.Expect(w.Code).To(gomega.Equal(http.StatusBadRequest))
+	body := make(map[string]interface{})
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	g.Expect(body["document"]).To(gomega.Equal(IssueField))
+	g.Expect(body["index"]).To(gomega.Equal(float64(1)))
+	g.Expect(body["field"]).To(gomega.HavePrefix("incidents."))
+	g.Expect(body["field"]).To(gomega.HaveSuffix(".line"))
+	var count int64
+	db.Model(&model.Analysis{}).Count(&count)
+	g.Expect(count).To(gomega.Equal(int64(1)))
+	db.Model(&model.Issue{}).Count(&count)
+	g.Expect(count).To(gomega.Equal(int64(2)))
+}
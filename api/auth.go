@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/konveyor/tackle2-hub/auth"
 	"net/http"
@@ -71,7 +72,7 @@ func (h AuthHandler) Refresh(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
-	token, err := auth.Remote.Refresh(r.Refresh)
+	token, err := h.refresh(r.Refresh)
 	if err != nil {
 		h.Respond(ctx,
 			http.StatusUnauthorized,
@@ -87,6 +88,22 @@ func (h AuthHandler) Refresh(ctx *gin.Context) {
 	h.Respond(ctx, http.StatusCreated, r)
 }
 
+//
+// refresh the token.
+// Builtin (task) tokens are refreshed by the hub provider.
+// Otherwise, the token is refreshed by the remote provider.
+func (h AuthHandler) refresh(refresh string) (token auth.Token, err error) {
+	token, err = auth.Hub.Refresh(refresh)
+	if err == nil && token.Access != "" {
+		return
+	}
+	if errors.Is(err, &auth.NotValid{}) {
+		return
+	}
+	token, err = auth.Remote.Refresh(refresh)
+	return
+}
+
 //
 // Login REST resource.
 type Login struct {
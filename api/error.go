@@ -1,12 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/konveyor/tackle2-hub/api/filter"
 	"github.com/konveyor/tackle2-hub/api/sort"
 	"github.com/konveyor/tackle2-hub/model"
+	tasking "github.com/konveyor/tackle2-hub/task"
 	"github.com/mattn/go-sqlite3"
 	"gorm.io/gorm"
 	"net/http"
@@ -28,6 +31,62 @@ func (r *BadRequestError) Is(err error) (matched bool) {
 	return
 }
 
+//
+// ConflictError reports requests that conflict with the
+// current state of the resource.
+type ConflictError struct {
+	Reason string
+}
+
+func (r *ConflictError) Error() string {
+	return r.Reason
+}
+
+func (r *ConflictError) Is(err error) (matched bool) {
+	_, matched = err.(*ConflictError)
+	return
+}
+
+//
+// DecodeError reports a document record that cannot be decoded.
+type DecodeError struct {
+	// Document name.
+	Document string
+	// Index of the record within the document.
+	Index int
+	// Field (path) within the record when known.
+	Field string
+	// Reason the decoder error.
+	Reason string
+}
+
+func (r *DecodeError) Error() (s string) {
+	s = fmt.Sprintf(
+		"Document (%s) record (%d)",
+		r.Document,
+		r.Index)
+	if r.Field != "" {
+		s += fmt.Sprintf(" field (%s)", r.Field)
+	}
+	s += " not valid: " + r.Reason
+	return
+}
+
+func (r *DecodeError) Is(err error) (matched bool) {
+	_, matched = err.(*DecodeError)
+	return
+}
+
+//
+// With updates the error with the decoder error.
+func (r *DecodeError) With(err error) {
+	r.Reason = err.Error()
+	jErr := &json.UnmarshalTypeError{}
+	if errors.As(err, &jErr) {
+		r.Field = jErr.Field
+	}
+}
+
 //
 // BatchError reports errors stemming from batch operations.
 type BatchError struct {
@@ -90,6 +149,30 @@ func ErrorHandler() gin.HandlerFunc {
 			return
 		}
 
+		dErr := &tasking.DataError{}
+		if errors.As(err, &dErr) {
+			rtx.Respond(
+				http.StatusBadRequest,
+				gin.H{
+					"error":  err.Error(),
+					"fields": dErr.Fields,
+				})
+			return
+		}
+
+		decodeErr := &DecodeError{}
+		if errors.As(err, &decodeErr) {
+			rtx.Respond(
+				http.StatusBadRequest,
+				gin.H{
+					"error":    err.Error(),
+					"document": decodeErr.Document,
+					"index":    decodeErr.Index,
+					"field":    decodeErr.Field,
+				})
+			return
+		}
+
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			if ctx.Request.Method == http.MethodDelete {
 				rtx.Status(http.StatusNoContent)
@@ -112,6 +195,15 @@ func ErrorHandler() gin.HandlerFunc {
 			return
 		}
 
+		if errors.Is(err, &ConflictError{}) {
+			rtx.Respond(
+				http.StatusConflict,
+				gin.H{
+					"error": err.Error(),
+				})
+			return
+		}
+
 		if errors.Is(err, model.DependencyCyclicError{}) {
 			rtx.Respond(
 				http.StatusConflict,
@@ -39,7 +39,9 @@ const (
 //
 // MIME Types.
 const (
+	MIMEGZIP        = "application/gzip"
 	MIMEOCTETSTREAM = "application/octet-stream"
+	MIMESARIF       = "application/sarif+json"
 )
 
 //
@@ -71,6 +73,7 @@ func All() []Handler {
 		&ProxyHandler{},
 		&ReviewHandler{},
 		&RuleSetHandler{},
+		&ScheduleHandler{},
 		&SchemaHandler{},
 		&SettingHandler{},
 		&StakeholderHandler{},
@@ -86,6 +89,7 @@ func All() []Handler {
 		&FileHandler{},
 		&MigrationWaveHandler{},
 		&BatchHandler{},
+		&WaiverHandler{},
 	}
 }
 
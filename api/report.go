@@ -0,0 +1,601 @@
+package api
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	liberr "github.com/jortel/go-utils/error"
+	"html/template"
+	"io"
+	"os"
+	pathlib "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// ReportArchiveName the (base) name of the report archive.
+const ReportArchiveName = "analysis-report"
+
+//
+// ReportArchive analysis report archive.
+// A self-contained (static) HTML report and CSV files.
+// The applications are rendered (to temporary files) as added
+// so that only the summary is retained. Must be closed.
+type ReportArchive struct {
+	Created      time.Time
+	Effort       int
+	Categories   []ReportCategory
+	Rules        []RuleReport
+	Applications []ReportAppSummary
+	Dependencies []DepReport
+	// dir the (temporary) directory of the rendered files.
+	dir string
+	// apps the rendered application (HTML) sections.
+	apps *reportWriter
+	// issues CSV.
+	issues *reportWriter
+	// incidents CSV.
+	incidents *reportWriter
+	// dependencies CSV.
+	dependencies *reportWriter
+	// categories by name.
+	categories map[string]*ReportCategory
+}
+
+//
+// ReportCategory issues collated by category.
+type ReportCategory struct {
+	Name      string
+	Rules     int
+	Incidents int
+	Effort    int
+}
+
+//
+// ReportApp application (analysis) report.
+type ReportApp struct {
+	Ref
+	Analysis     uint
+	Effort       int
+	Issues       []ReportIssue
+	Dependencies []DepReport
+}
+
+//
+// Incidents returns the number of incidents.
+func (r *ReportApp) Incidents() (n int) {
+	for i := range r.Issues {
+		n += r.Issues[i].Incidents
+	}
+	return
+}
+
+//
+// ReportAppSummary application (analysis) report summary.
+type ReportAppSummary struct {
+	Ref
+	Analysis     uint
+	Effort       int
+	Issues       int
+	Incidents    int
+	Dependencies int
+}
+
+//
+// With updates the summary with the application report.
+func (r *ReportAppSummary) With(m *ReportApp) {
+	r.Ref = m.Ref
+	r.Analysis = m.Analysis
+	r.Effort = m.Effort
+	r.Issues = len(m.Issues)
+	r.Incidents = m.Incidents()
+	r.Dependencies = len(m.Dependencies)
+}
+
+//
+// ReportIssue issue report.
+type ReportIssue struct {
+	IssueReport
+	Incidents int
+	Total     int
+	Locations []ReportFile
+}
+
+//
+// ReportFile incidents collated by file.
+type ReportFile struct {
+	File      string
+	Effort    int
+	Incidents []Incident
+}
+
+//
+// reportWriter a (temporary) file being rendered.
+type reportWriter struct {
+	path   string
+	file   *os.File
+	buffer *bufio.Writer
+	csv    *csv.Writer
+}
+
+//
+// Flush the buffered content to the file.
+func (r *reportWriter) Flush() (err error) {
+	if r.csv != nil {
+		r.csv.Flush()
+		err = r.csv.Error()
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+	}
+	err = r.buffer.Flush()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// Open creates the (temporary) files and writes the CSV headers.
+func (r *ReportArchive) Open() (err error) {
+	r.categories = make(map[string]*ReportCategory)
+	r.dir, err = os.MkdirTemp("", ReportArchiveName)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	r.apps, err = r.create("apps.html", nil)
+	if err != nil {
+		return
+	}
+	r.issues, err = r.create(
+		"issues.csv",
+		[]string{
+			"application",
+			"ruleset",
+			"rule",
+			"name",
+			"category",
+			"effort",
+			"files",
+			"incidents",
+			"labels",
+		})
+	if err != nil {
+		return
+	}
+	r.incidents, err = r.create(
+		"incidents.csv",
+		[]string{
+			"application",
+			"ruleset",
+			"rule",
+			"file",
+			"line",
+			"message",
+			"codeSnip",
+			"fingerprint",
+		})
+	if err != nil {
+		return
+	}
+	r.dependencies, err = r.create(
+		"dependencies.csv",
+		[]string{
+			"application",
+			"provider",
+			"name",
+			"version",
+			"sha",
+			"labels",
+		})
+	if err != nil {
+		return
+	}
+	return
+}
+
+//
+// Close the files and delete the (temporary) directory.
+func (r *ReportArchive) Close() {
+	for _, w := range []*reportWriter{r.apps, r.issues, r.incidents, r.dependencies} {
+		if w != nil {
+			_ = w.file.Close()
+		}
+	}
+	if r.dir != "" {
+		_ = os.RemoveAll(r.dir)
+		r.dir = ""
+	}
+}
+
+//
+// Add (renders) the application report.
+// Only the summary is retained.
+func (r *ReportArchive) Add(app *ReportApp) (err error) {
+	r.Effort += app.Effort
+	for i := range app.Issues {
+		issue := &app.Issues[i]
+		c := r.category(issue.Category)
+		c.Incidents += issue.Incidents
+		c.Effort += issue.Total
+	}
+	err = reportTemplate.ExecuteTemplate(r.apps.buffer, "app", app)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = r.addIssues(app)
+	if err != nil {
+		return
+	}
+	err = r.addIncidents(app)
+	if err != nil {
+		return
+	}
+	err = r.addDependencies(app)
+	if err != nil {
+		return
+	}
+	summary := ReportAppSummary{}
+	summary.With(app)
+	r.Applications = append(r.Applications, summary)
+	return
+}
+
+//
+// Write the archive (tar.gz).
+// The index is rendered using the summary and the rendered
+// application sections. The size of each file must be known
+// so nothing is written until all of the files are rendered.
+func (r *ReportArchive) Write(w io.Writer) (err error) {
+	r.categorize()
+	for _, f := range []*reportWriter{r.apps, r.issues, r.incidents, r.dependencies} {
+		err = f.Flush()
+		if err != nil {
+			return
+		}
+	}
+	index := pathlib.Join(r.dir, "index.html")
+	err = r.render(index)
+	if err != nil {
+		return
+	}
+	files := []struct {
+		path string
+		name string
+	}{
+		{path: index, name: "index.html"},
+		{path: r.issues.path, name: "issues.csv"},
+		{path: r.incidents.path, name: "incidents.csv"},
+		{path: r.dependencies.path, name: "dependencies.csv"},
+	}
+	zipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(zipWriter)
+	for _, f := range files {
+		err = r.add(tarWriter, f.path, f.name)
+		if err != nil {
+			return
+		}
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = zipWriter.Close()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// create a (temporary) file.
+// The CSV header is written when specified.
+func (r *ReportArchive) create(name string, header []string) (w *reportWriter, err error) {
+	w = &reportWriter{path: pathlib.Join(r.dir, name)}
+	w.file, err = os.Create(w.path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	w.buffer = bufio.NewWriter(w.file)
+	if header == nil {
+		return
+	}
+	w.csv = csv.NewWriter(w.buffer)
+	err = w.csv.Write(header)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// render the index at the specified path.
+// The rendered application sections are copied between
+// the summary and the dependencies.
+func (r *ReportArchive) render(path string) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	writer := bufio.NewWriter(file)
+	err = reportTemplate.ExecuteTemplate(writer, "head", r)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	apps, err := os.Open(r.apps.path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = apps.Close()
+	}()
+	_, err = io.Copy(writer, apps)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = reportTemplate.ExecuteTemplate(writer, "tail", r)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = writer.Flush()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// add the (rendered) file at the specified path to the archive.
+func (r *ReportArchive) add(tarWriter *tar.Writer, path, name string) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	st, err := file.Stat()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ReportArchiveName + "/" + name,
+		Mode:     0644,
+		Size:     st.Size(),
+		ModTime:  r.Created,
+	}
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	_, err = io.Copy(tarWriter, file)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// category returns the category (by name).
+func (r *ReportArchive) category(name string) (c *ReportCategory) {
+	c, found := r.categories[name]
+	if !found {
+		c = &ReportCategory{Name: name}
+		r.categories[name] = c
+	}
+	return
+}
+
+//
+// categorize collates the rules and (added) issues by category.
+func (r *ReportArchive) categorize() {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		r.category(rule.Category).Rules++
+	}
+	r.Categories = []ReportCategory{}
+	for _, c := range r.categories {
+		r.Categories = append(r.Categories, *c)
+	}
+	sort.Slice(
+		r.Categories,
+		func(i, j int) bool {
+			return r.Categories[i].Name < r.Categories[j].Name
+		})
+}
+
+//
+// addIssues writes the application issues CSV rows.
+func (r *ReportArchive) addIssues(app *ReportApp) (err error) {
+	for i := range app.Issues {
+		issue := &app.Issues[i]
+		err = r.issues.csv.Write(
+			[]string{
+				app.Name,
+				issue.RuleSet,
+				issue.Rule,
+				issue.Name,
+				issue.Category,
+				strconv.Itoa(issue.Effort),
+				strconv.Itoa(issue.Files),
+				strconv.Itoa(issue.Incidents),
+				strings.Join(issue.Labels, " "),
+			})
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+	}
+	return
+}
+
+//
+// addIncidents writes the application incidents CSV rows.
+func (r *ReportArchive) addIncidents(app *ReportApp) (err error) {
+	for i := range app.Issues {
+		issue := &app.Issues[i]
+		for _, file := range issue.Locations {
+			for _, n := range file.Incidents {
+				err = r.incidents.csv.Write(
+					[]string{
+						app.Name,
+						issue.RuleSet,
+						issue.Rule,
+						n.File,
+						strconv.Itoa(n.Line),
+						n.Message,
+						n.CodeSnip,
+						n.Fingerprint,
+					})
+				if err != nil {
+					err = liberr.Wrap(err)
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+//
+// addDependencies writes the application dependencies CSV rows.
+func (r *ReportArchive) addDependencies(app *ReportApp) (err error) {
+	for _, dep := range app.Dependencies {
+		err = r.dependencies.csv.Write(
+			[]string{
+				app.Name,
+				dep.Provider,
+				dep.Name,
+				dep.Version,
+				dep.SHA,
+				strings.Join(dep.Labels, " "),
+			})
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+	}
+	return
+}
+
+//
+// reportTemplate the (static) HTML report.
+// The index (head and tail) and the application sections
+// are rendered separately.
+var reportTemplate = template.Must(template.New("report").Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Analysis Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #151515; }
+h1, h2, h3 { font-weight: normal; }
+h3 { margin-top: 1.5em; }
+table { border-collapse: collapse; margin: 1em 0; width: 100%; }
+th, td { border: 1px solid #d2d2d2; padding: .3em .6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td.n, th.n { text-align: right; }
+details { margin: .3em 0 .3em 1em; }
+summary { cursor: pointer; }
+pre { background: #f5f5f5; border: 1px solid #d2d2d2; padding: .5em; overflow: auto; font-size: 85%; }
+.muted { color: #6a6e73; }
+.description { white-space: pre-wrap; }
+.mandatory { color: #c9190b; }
+.optional { color: #795600; }
+.potential { color: #0066cc; }
+</style>
+</head>
+<body>
+<h1>Analysis Report</h1>
+<p class="muted">Created: {{.Created.Format "2006-01-02 15:04:05 MST"}}</p>
+<h2>Applications</h2>
+<table>
+<tr><th>Application</th><th class="n">Analysis</th><th class="n">Issues</th><th class="n">Incidents</th><th class="n">Dependencies</th><th class="n">Effort</th></tr>
+{{- range .Applications}}
+<tr><td><a href="#app-{{.Analysis}}">{{.Name}}</a></td><td class="n">{{.Analysis}}</td><td class="n">{{.Issues}}</td><td class="n">{{.Incidents}}</td><td class="n">{{.Dependencies}}</td><td class="n">{{.Effort}}</td></tr>
+{{- end}}
+<tr><th colspan="5">Total</th><th class="n">{{.Effort}}</th></tr>
+</table>
+<h2>Issues by Category</h2>
+<table>
+<tr><th>Category</th><th class="n">Rules</th><th class="n">Incidents</th><th class="n">Effort</th></tr>
+{{- range .Categories}}
+<tr><td class="{{.Name}}">{{.Name}}</td><td class="n">{{.Rules}}</td><td class="n">{{.Incidents}}</td><td class="n">{{.Effort}}</td></tr>
+{{- end}}
+</table>
+<h2>Rules</h2>
+<table>
+<tr><th>Category</th><th>Rule</th><th>Name</th><th class="n">Effort</th><th class="n">Applications</th></tr>
+{{- range .Rules}}
+<tr><td class="{{.Category}}">{{.Category}}</td><td>{{.RuleSet}}/{{.Rule}}</td><td>{{.Name}}</td><td class="n">{{.Effort}}</td><td class="n">{{.Applications}}</td></tr>
+{{- end}}
+</table>
+{{end}}
+{{- define "app"}}
+<h2 id="app-{{.Analysis}}">{{.Name}}</h2>
+<p class="muted">Analysis: {{.Analysis}} Effort: {{.Effort}}</p>
+{{- range .Issues}}
+<h3><span class="{{.Category}}">[{{.Category}}]</span> {{.Name}} <span class="muted">{{.RuleSet}}/{{.Rule}}</span></h3>
+{{- if .Description}}
+<p class="description">{{.Description}}</p>
+{{- end}}
+{{- if .Links}}
+<ul>
+{{- range .Links}}
+<li><a href="{{.URL}}">{{or .Title .URL}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+<p class="muted">Files: {{.Files}} Incidents: {{.Incidents}} Effort: {{.Total}}</p>
+{{- range .Locations}}
+<details>
+<summary>{{.File}} ({{len .Incidents}})</summary>
+{{- range .Incidents}}
+<p>Line {{.Line}}: {{.Message}}</p>
+{{- if .CodeSnip}}
+<pre>{{.CodeSnip}}</pre>
+{{- end}}
+{{- end}}
+</details>
+{{- end}}
+{{- else}}
+<p class="muted">No issues.</p>
+{{- end}}
+{{end}}
+{{- define "tail"}}
+<h2>Dependencies</h2>
+<table>
+<tr><th>Provider</th><th>Name</th><th>Version</th><th>SHA</th><th>Labels</th><th class="n">Applications</th></tr>
+{{- range .Dependencies}}
+<tr><td>{{.Provider}}</td><td>{{.Name}}</td><td>{{.Version}}</td><td>{{.SHA}}</td><td>{{range .Labels}}{{.}} {{end}}</td><td class="n">{{.Applications}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+{{end}}`))
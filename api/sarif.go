@@ -0,0 +1,312 @@
+package api
+
+import (
+	"encoding/json"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/model"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//
+// SARIF
+const (
+	SarifVersion = "2.1.0"
+	SarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	SarifTool    = "konveyor"
+	SarifToolURI = "https://konveyor.io"
+)
+
+//
+// Sarif (2.1.0) log REST resource.
+// Each analysis is reported as a run.
+type Sarif struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+//
+// SarifWriter encodes the SARIF log incrementally.
+// Each run is encoded as written so that only one
+// run (analysis) need be in memory.
+type SarifWriter struct {
+	writer  io.Writer
+	encoder *json.Encoder
+	runs    int
+}
+
+//
+// Begin writes the log header and opens the runs.
+func (r *SarifWriter) Begin(writer io.Writer) (err error) {
+	r.writer = writer
+	r.encoder = json.NewEncoder(writer)
+	r.runs = 0
+	_, err = io.WriteString(writer, `{"$schema":`)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = r.encoder.Encode(SarifSchema)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	_, err = io.WriteString(writer, `,"version":`)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = r.encoder.Encode(SarifVersion)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	_, err = io.WriteString(writer, `,"runs":[`)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// Write encodes the run.
+func (r *SarifWriter) Write(run *SarifRun) (err error) {
+	if r.runs > 0 {
+		_, err = io.WriteString(r.writer, ",")
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+	}
+	err = r.encoder.Encode(run)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	r.runs++
+	return
+}
+
+//
+// End closes the runs and the log.
+func (r *SarifWriter) End() (err error) {
+	_, err = io.WriteString(r.writer, "]}")
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	return
+}
+
+//
+// SarifRun a SARIF run.
+type SarifRun struct {
+	Tool struct {
+		Driver SarifDriver `json:"driver"`
+	} `json:"tool"`
+	AutomationDetails struct {
+		ID string `json:"id"`
+	} `json:"automationDetails"`
+	Results    []SarifResult `json:"results"`
+	Properties SarifProps    `json:"properties,omitempty"`
+}
+
+//
+// With updates the run with the analysis.
+// The analysis must include the issues, incidents and
+// application. Issues are reported as rules and incidents
+// as results. Waived incidents are reported as suppressed.
+func (r *SarifRun) With(m *model.Analysis, waived *WaivedIssues) {
+	r.Tool.Driver.Name = SarifTool
+	r.Tool.Driver.InformationURI = SarifToolURI
+	r.Tool.Driver.Rules = []SarifRule{}
+	r.Results = []SarifResult{}
+	r.Properties = SarifProps{
+		"analysis": m.ID,
+		"effort":   m.Effort,
+	}
+	r.AutomationDetails.ID = "analysis/" + strconv.Itoa(int(m.ID))
+	if m.Application != nil {
+		r.AutomationDetails.ID = m.Application.Name + "/" + strconv.Itoa(int(m.ID))
+		r.Properties["application"] = Ref{
+			ID:   m.Application.ID,
+			Name: m.Application.Name,
+		}
+	}
+	for i := range m.Issues {
+		issue := &m.Issues[i]
+		rule := SarifRule{}
+		rule.With(issue)
+		index := len(r.Tool.Driver.Rules)
+		r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule)
+		for j := range issue.Incidents {
+			incident := &issue.Incidents[j]
+			result := SarifResult{}
+			result.With(issue, incident)
+			result.RuleIndex = index
+			if waived.IncidentWaived(issue.ID, incident.ID) {
+				result.Suppressions = []SarifSuppression{
+					{Kind: "external", Status: "accepted"},
+				}
+			}
+			r.Results = append(r.Results, result)
+		}
+	}
+}
+
+//
+// SarifDriver a SARIF tool component.
+type SarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SarifRule `json:"rules"`
+}
+
+//
+// SarifRule a SARIF reporting descriptor.
+type SarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name,omitempty"`
+	ShortDescription *SarifText   `json:"shortDescription,omitempty"`
+	FullDescription  *SarifText   `json:"fullDescription,omitempty"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+	Config           *SarifConfig `json:"defaultConfiguration,omitempty"`
+	Properties       SarifProps   `json:"properties,omitempty"`
+}
+
+//
+// With updates the rule with the issue.
+// The labels are reported as (property) tags.
+func (r *SarifRule) With(m *model.Issue) {
+	r.ID = m.RuleSet + "/" + m.Rule
+	r.Name = m.Rule
+	if m.Name != "" {
+		r.ShortDescription = &SarifText{Text: m.Name}
+	}
+	if m.Description != "" {
+		r.FullDescription = &SarifText{Text: m.Description}
+	}
+	r.Config = &SarifConfig{Level: SarifLevel(m.Category)}
+	r.Properties = SarifProps{
+		"ruleset":  m.RuleSet,
+		"category": m.Category,
+		"effort":   m.Effort,
+	}
+	var links []Link
+	if m.Links != nil {
+		_ = json.Unmarshal(m.Links, &links)
+	}
+	if len(links) > 0 {
+		r.HelpURI = links[0].URL
+		r.Properties["links"] = links
+	}
+	var labels []string
+	if m.Labels != nil {
+		_ = json.Unmarshal(m.Labels, &labels)
+	}
+	if len(labels) > 0 {
+		r.Properties["tags"] = labels
+	}
+}
+
+//
+// SarifResult a SARIF result.
+type SarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      SarifText          `json:"message"`
+	Locations    []SarifLocation    `json:"locations"`
+	Fingerprints map[string]string  `json:"partialFingerprints,omitempty"`
+	Suppressions []SarifSuppression `json:"suppressions,omitempty"`
+	Properties   SarifProps         `json:"properties,omitempty"`
+}
+
+//
+// With updates the result with the incident.
+// A region requires the (start) line so the snippet of
+// an incident without a line is reported as a property.
+func (r *SarifResult) With(issue *model.Issue, m *model.Incident) {
+	r.RuleID = issue.RuleSet + "/" + issue.Rule
+	r.Level = SarifLevel(issue.Category)
+	r.Message.Text = m.Message
+	if r.Message.Text == "" {
+		r.Message.Text = issue.Name
+	}
+	r.Fingerprints = map[string]string{
+		"incident/v1": Fingerprint(m),
+	}
+	location := SarifLocation{}
+	location.Physical.Artifact.URI = m.File
+	if m.Line > 0 {
+		region := &SarifRegion{}
+		region.StartLine = m.Line
+		if m.CodeSnip != "" {
+			region.Snippet = &SarifText{Text: m.CodeSnip}
+		}
+		location.Physical.Region = region
+	} else if m.CodeSnip != "" {
+		r.Properties = SarifProps{
+			"snippet": m.CodeSnip,
+		}
+	}
+	r.Locations = []SarifLocation{location}
+}
+
+//
+// SarifLocation a SARIF location.
+type SarifLocation struct {
+	Physical struct {
+		Artifact struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *SarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+//
+// SarifRegion a SARIF region.
+type SarifRegion struct {
+	StartLine int        `json:"startLine,omitempty"`
+	Snippet   *SarifText `json:"snippet,omitempty"`
+}
+
+//
+// SarifSuppression a SARIF suppression.
+type SarifSuppression struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status,omitempty"`
+}
+
+//
+// SarifConfig a SARIF reporting configuration.
+type SarifConfig struct {
+	Level string `json:"level"`
+}
+
+//
+// SarifText a SARIF message.
+type SarifText struct {
+	Text string `json:"text"`
+}
+
+//
+// SarifProps a SARIF property bag.
+type SarifProps map[string]interface{}
+
+//
+// SarifLevel returns the SARIF level for the issue category.
+func SarifLevel(category string) (level string) {
+	switch strings.ToLower(category) {
+	case "mandatory":
+		level = "error"
+	case "optional":
+		level = "warning"
+	default:
+		level = "note"
+	}
+	return
+}
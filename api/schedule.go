@@ -0,0 +1,393 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/model"
+	tasking "github.com/konveyor/tackle2-hub/task"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+//
+// Routes
+const (
+	SchedulesRoot      = "/schedules"
+	ScheduleRoot       = SchedulesRoot + "/:" + ID
+	SchedulePauseRoot  = ScheduleRoot + "/pause"
+	ScheduleResumeRoot = ScheduleRoot + "/resume"
+)
+
+//
+// ScheduleHandler handles schedule routes.
+type ScheduleHandler struct {
+	BaseHandler
+}
+
+//
+// AddRoutes adds routes.
+func (h ScheduleHandler) AddRoutes(e *gin.Engine) {
+	routeGroup := e.Group("/")
+	routeGroup.Use(Required("schedules"))
+	routeGroup.GET(SchedulesRoot, h.List)
+	routeGroup.GET(SchedulesRoot+"/", h.List)
+	routeGroup.POST(SchedulesRoot, h.Create)
+	routeGroup.GET(ScheduleRoot, h.Get)
+	routeGroup.PUT(ScheduleRoot, h.Update)
+	routeGroup.DELETE(ScheduleRoot, h.Delete)
+	routeGroup.PUT(SchedulePauseRoot, h.Pause)
+	routeGroup.PUT(ScheduleResumeRoot, h.Resume)
+}
+
+// Get godoc
+// @summary Get a schedule by ID.
+// @description Get a schedule by ID.
+// @tags schedules
+// @produce json
+// @success 200 {object} api.Schedule
+// @router /schedules/{id} [get]
+// @param id path string true "Schedule ID"
+func (h ScheduleHandler) Get(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Schedule{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	r := Schedule{}
+	r.With(m)
+
+	h.Respond(ctx, http.StatusOK, r)
+}
+
+// List godoc
+// @summary List all schedules.
+// @description List all schedules.
+// @tags schedules
+// @produce json
+// @success 200 {object} []api.Schedule
+// @router /schedules [get]
+func (h ScheduleHandler) List(ctx *gin.Context) {
+	var list []model.Schedule
+	result := h.DB(ctx).Find(&list)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	resources := []Schedule{}
+	for i := range list {
+		r := Schedule{}
+		r.With(&list[i])
+		resources = append(resources, r)
+	}
+
+	h.Respond(ctx, http.StatusOK, resources)
+}
+
+// Create godoc
+// @summary Create a schedule.
+// @description Create a schedule.
+// @description Either a task or task group template must be specified.
+// @description The template is submitted (validated) as when tasks are created.
+// @tags schedules
+// @accept json
+// @produce json
+// @success 201 {object} api.Schedule
+// @router /schedules [post]
+// @param schedule body api.Schedule true "Schedule data"
+func (h ScheduleHandler) Create(ctx *gin.Context) {
+	r := &Schedule{}
+	err := h.Bind(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = r.Validate()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = h.templateSubmitted(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m := r.Model()
+	if !m.Paused {
+		next, _ := NextRun(m.Cron, time.Now())
+		m.NextRun = &next
+	}
+	m.CreateUser = h.BaseHandler.CurrentUser(ctx)
+	result := h.DB(ctx).Create(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	r.With(m)
+
+	h.Respond(ctx, http.StatusCreated, r)
+}
+
+// Delete godoc
+// @summary Delete a schedule.
+// @description Delete a schedule.
+// @tags schedules
+// @success 204
+// @router /schedules/{id} [delete]
+// @param id path string true "Schedule ID"
+func (h ScheduleHandler) Delete(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Schedule{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	result = h.DB(ctx).Delete(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+// Update godoc
+// @summary Update a schedule.
+// @description Update a schedule.
+// @description The next run is recalculated.
+// @tags schedules
+// @accept json
+// @success 204
+// @router /schedules/{id} [put]
+// @param id path string true "Schedule ID"
+// @param schedule body api.Schedule true "Schedule data"
+func (h ScheduleHandler) Update(ctx *gin.Context) {
+	id := h.pk(ctx)
+	r := &Schedule{}
+	err := h.Bind(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = r.Validate()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = h.templateSubmitted(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m := r.Model()
+	m.ID = id
+	m.UpdateUser = h.BaseHandler.CurrentUser(ctx)
+	if !m.Paused {
+		next, _ := NextRun(m.Cron, time.Now())
+		m.NextRun = &next
+	}
+	db := h.DB(ctx).Model(m)
+	db = db.Omit(clause.Associations, "LastRun", "Errors")
+	result := db.Updates(h.fields(m))
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+// Pause godoc
+// @summary Pause a schedule.
+// @description Pause a schedule.
+// @tags schedules
+// @success 204
+// @router /schedules/{id}/pause [put]
+// @param id path string true "Schedule ID"
+func (h ScheduleHandler) Pause(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Schedule{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	m.Paused = true
+	m.NextRun = nil
+	m.UpdateUser = h.BaseHandler.CurrentUser(ctx)
+	db := h.DB(ctx).Model(m)
+	db = db.Select("Paused", "NextRun", "UpdateUser")
+	result = db.Updates(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+// Resume godoc
+// @summary Resume a (paused) schedule.
+// @description Resume a (paused) schedule.
+// @description Runs missed while paused are skipped.
+// @tags schedules
+// @success 204
+// @router /schedules/{id}/resume [put]
+// @param id path string true "Schedule ID"
+func (h ScheduleHandler) Resume(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Schedule{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	next, err := NextRun(m.Cron, time.Now())
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m.Paused = false
+	m.NextRun = &next
+	m.UpdateUser = h.BaseHandler.CurrentUser(ctx)
+	db := h.DB(ctx).Model(m)
+	db = db.Select("Paused", "NextRun", "UpdateUser")
+	result = db.Updates(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+//
+// templateSubmitted submits (a copy of) the template tasks
+// as when tasks are created. The template is not updated.
+func (h ScheduleHandler) templateSubmitted(ctx *gin.Context, r *Schedule) (err error) {
+	if r.Task != nil {
+		m := r.Task.Model()
+		err = h.submitted(ctx, m)
+		return
+	}
+	group := r.TaskGroup.Model()
+	group.State = tasking.Ready
+	err = group.Propagate()
+	if err != nil {
+		err = &BadRequestError{err.Error()}
+		return
+	}
+	for i := range group.Tasks {
+		err = h.submitted(ctx, &group.Tasks[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//
+// Schedule REST resource.
+// The task and task group templates are stored as resources.
+type Schedule struct {
+	Resource
+	Name      string      `json:"name" binding:"required"`
+	Cron      string      `json:"cron" binding:"required"`
+	Paused    bool        `json:"paused,omitempty"`
+	Task      *Task       `json:"task,omitempty"`
+	TaskGroup *TaskGroup  `json:"taskGroup,omitempty"`
+	LastRun   *time.Time  `json:"lastRun,omitempty"`
+	NextRun   *time.Time  `json:"nextRun,omitempty"`
+	Errors    []TaskError `json:"errors,omitempty"`
+}
+
+//
+// With updates the resource with the model.
+func (r *Schedule) With(m *model.Schedule) {
+	r.Resource.With(&m.Model)
+	r.Name = m.Name
+	r.Cron = m.Cron
+	r.Paused = m.Paused
+	r.LastRun = m.LastRun
+	r.NextRun = m.NextRun
+	r.Task = nil
+	r.TaskGroup = nil
+	if m.Task != nil {
+		task := &Task{}
+		err := json.Unmarshal(m.Task, task)
+		if err == nil {
+			r.Task = task
+		}
+	}
+	if m.TaskGroup != nil {
+		group := &TaskGroup{}
+		err := json.Unmarshal(m.TaskGroup, group)
+		if err == nil {
+			r.TaskGroup = group
+		}
+	}
+	if m.Errors != nil {
+		_ = json.Unmarshal(m.Errors, &r.Errors)
+	}
+}
+
+//
+// Model builds a model.
+func (r *Schedule) Model() (m *model.Schedule) {
+	m = &model.Schedule{
+		Name:   r.Name,
+		Cron:   r.Cron,
+		Paused: r.Paused,
+	}
+	m.ID = r.ID
+	if r.Task != nil {
+		task := *r.Task
+		task.Resource = Resource{}
+		task.State = ""
+		m.Task, _ = json.Marshal(task)
+	}
+	if r.TaskGroup != nil {
+		group := *r.TaskGroup
+		group.Resource = Resource{}
+		group.State = ""
+		m.TaskGroup, _ = json.Marshal(group)
+	}
+	return
+}
+
+//
+// Validate the resource.
+func (r *Schedule) Validate() (err error) {
+	if (r.Task == nil) == (r.TaskGroup == nil) {
+		err = &BadRequestError{
+			Reason: "Either task or taskGroup must be specified.",
+		}
+		return
+	}
+	_, err = NextRun(r.Cron, time.Now())
+	if err != nil {
+		err = &BadRequestError{
+			Reason: err.Error(),
+		}
+		return
+	}
+	return
+}
+
+//
+// NextRun returns the next time (after t) matched by the
+// (standard) cron expression.
+func NextRun(expression string, t time.Time) (next time.Time, err error) {
+	parsed, err := cron.ParseStandard(expression)
+	if err != nil {
+		err = liberr.Wrap(err, "cron", expression)
+		return
+	}
+	next = parsed.Next(t)
+	return
+}
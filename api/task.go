@@ -2,13 +2,24 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+	qf "github.com/konveyor/tackle2-hub/api/filter"
 	"github.com/konveyor/tackle2-hub/model"
 	tasking "github.com/konveyor/tackle2-hub/task"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"io"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"net/http"
+	"os"
+	pathlib "path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,17 +28,33 @@ import (
 const (
 	TasksRoot             = "/tasks"
 	TaskRoot              = TasksRoot + "/:" + ID
+	TaskStreamRoot        = TasksRoot + "/stream"
+	TaskQueueRoot         = TasksRoot + "/queue"
 	TaskReportRoot        = TaskRoot + "/report"
 	TaskBucketRoot        = TaskRoot + "/bucket"
 	TaskBucketContentRoot = TaskBucketRoot + "/*" + Wildcard
 	TaskSubmitRoot        = TaskRoot + "/submit"
 	TaskCancelRoot        = TaskRoot + "/cancel"
+	TaskEventsRoot        = TaskRoot + "/events"
+	TaskLogRoot           = TaskRoot + "/log"
+	TaskRerunRoot         = TaskRoot + "/rerun"
+	TasksBulkRoot         = TasksRoot + "/bulk"
+	TasksBulkCancelRoot   = TasksBulkRoot + "/cancel"
+	TasksBulkRetryRoot    = TasksBulkRoot + "/retry"
 )
 
 const (
 	LocatorParam = "locator"
 )
 
+//
+// Stream params.
+const (
+	TaskParam        = "task"
+	TaskGroupParam   = "taskGroup"
+	ApplicationParam = "application"
+)
+
 //
 // TaskHandler handles task routes.
 type TaskHandler struct {
@@ -45,9 +72,17 @@ func (h TaskHandler) AddRoutes(e *gin.Engine) {
 	routeGroup.GET(TaskRoot, h.Get)
 	routeGroup.PUT(TaskRoot, h.Update)
 	routeGroup.DELETE(TaskRoot, h.Delete)
+	routeGroup.GET(TaskEventsRoot, h.Events)
+	routeGroup.GET(TaskLogRoot, h.Log)
+	routeGroup.GET(TaskStreamRoot, h.Stream)
+	routeGroup.GET(TaskQueueRoot, h.Queue)
 	// Actions
 	routeGroup.PUT(TaskSubmitRoot, h.Submit, h.Update)
 	routeGroup.PUT(TaskCancelRoot, h.Cancel)
+	routeGroup.POST(TaskRerunRoot, h.Rerun)
+	routeGroup.PUT(TasksBulkCancelRoot, h.BulkCancel)
+	routeGroup.PUT(TasksBulkRetryRoot, h.BulkRetry)
+	routeGroup.DELETE(TasksBulkRoot, h.BulkDelete)
 	// Bucket
 	routeGroup = e.Group("/")
 	routeGroup.Use(Required("tasks.bucket"))
@@ -67,6 +102,7 @@ func (h TaskHandler) AddRoutes(e *gin.Engine) {
 // Get godoc
 // @summary Get a task by ID.
 // @description Get a task by ID.
+// @description For pipeline stages, the previous stage and its result are included.
 // @tags tasks
 // @produce json
 // @success 200 {object} api.Task
@@ -83,6 +119,15 @@ func (h TaskHandler) Get(ctx *gin.Context) {
 	}
 	r := Task{}
 	r.With(task)
+	previous, err := tasking.PreviousStage(h.DB(ctx), task)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	if previous != nil {
+		r.Previous = &TaskPrevious{}
+		r.Previous.With(previous)
+	}
 
 	h.Respond(ctx, http.StatusOK, r)
 }
@@ -133,6 +178,14 @@ func (h TaskHandler) Create(ctx *gin.Context) {
 		_ = ctx.Error(err)
 		return
 	}
+	if r.Addon == "" && r.Kind == "" {
+		h.Respond(ctx,
+			http.StatusBadRequest,
+			gin.H{
+				"error": "addon or kind required.",
+			})
+		return
+	}
 	switch r.State {
 	case "":
 		r.State = tasking.Created
@@ -147,8 +200,16 @@ func (h TaskHandler) Create(ctx *gin.Context) {
 		return
 	}
 	m := r.Model()
+	if m.State == tasking.Ready {
+		err = h.submitted(ctx, m)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
 	m.CreateUser = h.BaseHandler.CurrentUser(ctx)
-	result := h.DB(ctx).Create(&m)
+	db := h.DB(ctx).Omit("DependsOn.*")
+	result := db.Create(&m)
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
 		return
@@ -173,17 +234,9 @@ func (h TaskHandler) Delete(ctx *gin.Context) {
 		_ = ctx.Error(result.Error)
 		return
 	}
-	rt := tasking.Task{Task: task}
-	err := rt.Delete(h.Client(ctx))
+	err := h.deleteTask(ctx, task)
 	if err != nil {
-		if !k8serr.IsNotFound(err) {
-			_ = ctx.Error(err)
-			return
-		}
-	}
-	result = h.DB(ctx).Delete(task)
-	if result.Error != nil {
-		_ = ctx.Error(result.Error)
+		_ = ctx.Error(err)
 		return
 	}
 
@@ -218,9 +271,16 @@ func (h TaskHandler) Update(ctx *gin.Context) {
 		return
 	}
 	m := r.Model()
+	m.ID = id
 	m.Reset()
+	if m.State == tasking.Ready {
+		err = h.submitted(ctx, m)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
 	db := h.DB(ctx).Model(m)
-	db = db.Where("id", id)
 	db = db.Where("state", tasking.Created)
 	db = h.omitted(db)
 	result := db.Updates(h.fields(m))
@@ -228,6 +288,14 @@ func (h TaskHandler) Update(ctx *gin.Context) {
 		_ = ctx.Error(result.Error)
 		return
 	}
+	if result.RowsAffected > 0 {
+		db = h.DB(ctx).Omit("DependsOn.*").Model(m)
+		err = db.Association("DependsOn").Replace(m.DependsOn)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
 
 	h.Status(ctx, http.StatusNoContent)
 }
@@ -247,7 +315,8 @@ func (h TaskHandler) Submit(ctx *gin.Context) {
 	mod := func(withBody bool) (err error) {
 		if !withBody {
 			m := r.Model()
-			err = h.DB(ctx).First(m, id).Error
+			db := h.DB(ctx).Preload("DependsOn")
+			err = db.First(m, id).Error
 			if err != nil {
 				return
 			}
@@ -279,33 +348,530 @@ func (h TaskHandler) Cancel(ctx *gin.Context) {
 		_ = ctx.Error(result.Error)
 		return
 	}
+	err := h.cancelTask(ctx, m)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+// Rerun godoc
+// @summary Rerun a task.
+// @description Creates (and submits) a new task using the addon, data, variant,
+// @description policy, priority and application of an existing task.
+// @description The addon is selected again when the task specifies a kind.
+// @description The data is optionally overridden.
+// @description The new task references the original as its origin.
+// @description Only terminated (Succeeded|Failed|Canceled) tasks may be rerun.
+// @tags tasks
+// @accept json
+// @produce json
+// @success 201 {object} api.Task
+// @router /tasks/{id}/rerun [post]
+// @param id path string true "Task ID"
+// @param rerun body TaskRerun false "Rerun options (optional)"
+func (h TaskHandler) Rerun(ctx *gin.Context) {
+	id := h.pk(ctx)
+	origin := &model.Task{}
+	err := h.DB(ctx).First(origin, id).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	switch origin.State {
+	case tasking.Succeeded,
+		tasking.Failed,
+		tasking.Canceled:
+	default:
+		_ = ctx.Error(&ConflictError{"task must be (Succeeded|Failed|Canceled)"})
+		return
+	}
+	r := TaskRerun{}
+	if ctx.Request.ContentLength > 0 {
+		err = h.Bind(ctx, &r)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
+	switch r.State {
+	case "":
+		r.State = tasking.Ready
+	case tasking.Created,
+		tasking.Ready:
+	default:
+		_ = ctx.Error(&BadRequestError{"state must be (''|Created|Ready)"})
+		return
+	}
+	m := &model.Task{
+		Name:          origin.Name,
+		Addon:         origin.Addon,
+		Kind:          origin.Kind,
+		Data:          origin.Data,
+		Variant:       origin.Variant,
+		Policy:        origin.Policy,
+		Priority:      origin.Priority,
+		TTL:           origin.TTL,
+		Deadline:      origin.Deadline,
+		RetryPolicy:   origin.RetryPolicy,
+		ApplicationID: origin.ApplicationID,
+		State:         r.State,
+		OriginID:      &origin.ID,
+	}
+	if m.Kind != "" {
+		m.Addon = ""
+	}
+	if r.Data != nil {
+		m.Data, _ = json.Marshal(r.Data)
+	}
+	if m.State == tasking.Ready {
+		err = h.submitted(ctx, m)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
+	}
+	m.CreateUser = h.BaseHandler.CurrentUser(ctx)
+	err = h.DB(ctx).Create(m).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m.Origin = origin
+	task := Task{}
+	task.With(m)
+
+	h.Respond(ctx, http.StatusCreated, task)
+}
+
+// BulkCancel godoc
+// @summary Cancel the tasks matched by the filter.
+// @description Cancel the tasks matched by the filter.
+// @description filters:
+// @description - id
+// @description - name
+// @description - state
+// @description - addon
+// @description - application
+// @description - taskGroup
+// @description - createUser
+// @tags tasks
+// @produce json
+// @success 200 {object} []api.TaskBulkResult
+// @router /tasks/bulk/cancel [put]
+func (h TaskHandler) BulkCancel(ctx *gin.Context) {
+	h.bulk(ctx, h.cancelTask)
+}
+
+// BulkRetry godoc
+// @summary Retry (resubmit) the terminated tasks matched by the filter.
+// @description Retry (resubmit) the terminated tasks matched by the filter.
+// @description filters:
+// @description - id
+// @description - name
+// @description - state
+// @description - addon
+// @description - application
+// @description - taskGroup
+// @description - createUser
+// @tags tasks
+// @produce json
+// @success 200 {object} []api.TaskBulkResult
+// @router /tasks/bulk/retry [put]
+func (h TaskHandler) BulkRetry(ctx *gin.Context) {
+	h.bulk(ctx, h.retryTask)
+}
+
+// BulkDelete godoc
+// @summary Delete the tasks matched by the filter.
+// @description Delete the tasks matched by the filter.
+// @description filters:
+// @description - id
+// @description - name
+// @description - state
+// @description - addon
+// @description - application
+// @description - taskGroup
+// @description - createUser
+// @tags tasks
+// @produce json
+// @success 200 {object} []api.TaskBulkResult
+// @router /tasks/bulk [delete]
+func (h TaskHandler) BulkDelete(ctx *gin.Context) {
+	h.bulk(ctx, h.deleteTask)
+}
+
+//
+// bulk applies the operation to each task matched by the filter.
+// A filter is required.
+func (h TaskHandler) bulk(ctx *gin.Context, operation TaskOperation) {
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "id", Kind: qf.LITERAL},
+			{Field: "name", Kind: qf.STRING},
+			{Field: "state", Kind: qf.STRING},
+			{Field: "addon", Kind: qf.STRING},
+			{Field: "application", Kind: qf.LITERAL},
+			{Field: "taskGroup", Kind: qf.LITERAL},
+			{Field: "createUser", Kind: qf.STRING},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	if filter.Empty() {
+		_ = ctx.Error(&BadRequestError{"filter required."})
+		return
+	}
+	db := h.DB(ctx)
+	db = filter.Where(db, "-application", "-taskGroup")
+	if f, found := filter.Field("application"); found {
+		f = f.As("ApplicationID")
+		db = f.Where(db)
+	}
+	if f, found := filter.Field("taskGroup"); found {
+		f = f.As("TaskGroupID")
+		db = f.Where(db)
+	}
+	var list []model.Task
+	err = db.Find(&list).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	h.Respond(ctx, http.StatusOK, h.apply(ctx, list, operation))
+}
+
+//
+// TaskOperation an operation on a task.
+type TaskOperation func(ctx *gin.Context, m *model.Task) (err error)
+
+//
+// apply the operation to each task.
+// Returns the result for each task.
+func (h *BaseHandler) apply(ctx *gin.Context, list []model.Task, operation TaskOperation) (results []TaskBulkResult) {
+	results = []TaskBulkResult{}
+	for i := range list {
+		m := &list[i]
+		r := TaskBulkResult{ID: m.ID}
+		err := operation(ctx, m)
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+//
+// cancelTask cancels the task.
+// The task is canceled by the task manager.
+func (h *BaseHandler) cancelTask(ctx *gin.Context, m *model.Task) (err error) {
 	switch m.State {
 	case tasking.Succeeded,
 		tasking.Failed,
+		tasking.TimedOut,
 		tasking.Canceled:
-		h.Respond(ctx,
-			http.StatusBadRequest,
-			gin.H{
-				"error": "state must not be (Succeeded|Failed|Canceled)",
-			})
+		err = &BadRequestError{
+			"state must not be (Succeeded|Failed|TimedOut|Canceled)",
+		}
 		return
 	}
 	db := h.DB(ctx).Model(m)
-	db = db.Where("id", id)
+	db = db.Where("id", m.ID)
 	db = db.Where(
 		"state not IN ?",
 		[]string{
 			tasking.Succeeded,
 			tasking.Failed,
+			tasking.TimedOut,
 			tasking.Canceled,
 		})
-	err := db.Update("Canceled", true).Error
+	err = db.Update("Canceled", true).Error
+	return
+}
+
+//
+// retryTask resubmits the (terminated) task.
+// The retry count and report are reset and the
+// addon selected (by kind) again.
+func (h *BaseHandler) retryTask(ctx *gin.Context, m *model.Task) (err error) {
+	switch m.State {
+	case tasking.Failed,
+		tasking.TimedOut,
+		tasking.Canceled:
+	default:
+		err = &BadRequestError{
+			"state must be (Failed|TimedOut|Canceled)",
+		}
+		return
+	}
+	m.Reset()
+	m.State = tasking.Ready
+	m.Retries = 0
+	m.Canceled = false
+	m.Pod = ""
+	if m.Kind != "" {
+		m.Addon = ""
+	}
+	err = h.submitted(ctx, m)
+	if err != nil {
+		return
+	}
+	m.Because("Resubmitted.")
+	db := h.DB(ctx).Omit(clause.Associations)
+	err = db.Save(m).Error
+	if err != nil {
+		return
+	}
+	db = h.DB(ctx).Where("TaskID", m.ID)
+	err = db.Delete(&model.TaskReport{}).Error
+	return
+}
+
+//
+// deleteTask deletes the task and the execution.
+func (h *BaseHandler) deleteTask(ctx *gin.Context, m *model.Task) (err error) {
+	rt := tasking.Task{Task: m}
+	err = rt.Delete(h.Client(ctx))
+	if err != nil {
+		if !k8serr.IsNotFound(err) {
+			return
+		}
+		err = nil
+	}
+	err = h.DB(ctx).Delete(m).Error
+	return
+}
+
+// Events godoc
+// @summary List task (state transition) events.
+// @description List task (state transition) events.
+// @tags tasks
+// @produce json
+// @success 200 {object} []api.TaskEvent
+// @router /tasks/{id}/events [get]
+// @param id path string true "Task ID"
+func (h TaskHandler) Events(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Task{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	var list []model.TaskEvent
+	db := h.DB(ctx).Where("TaskID", id)
+	db = db.Order("ID")
+	result = db.Find(&list)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	resources := []TaskEvent{}
+	for i := range list {
+		r := TaskEvent{}
+		r.With(&list[i])
+		resources = append(resources, r)
+	}
+
+	h.Respond(ctx, http.StatusOK, resources)
+}
+
+// Log godoc
+// @summary Get the task log.
+// @description Get the task log.
+// @description The live log is followed while the task is running.
+// @description Else, the logs captured in the task bucket (for each attempt) are returned.
+// @tags tasks
+// @produce plain
+// @success 200
+// @router /tasks/{id}/log [get]
+// @param id path string true "Task ID"
+func (h TaskHandler) Log(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Task{}
+	db := h.DB(ctx).Preload("Bucket")
+	result := db.First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	var logs []tasking.ExecutionLog
+	if m.State == tasking.Running {
+		rt := tasking.Task{Task: m}
+		logs, _ = rt.Logs(h.Client(ctx), true)
+	}
+	if logs == nil {
+		if m.Bucket == nil {
+			h.Status(ctx, http.StatusNotFound)
+			return
+		}
+		dir := pathlib.Join(m.Bucket.Path, tasking.LogDir)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				h.Status(ctx, http.StatusNotFound)
+			} else {
+				_ = ctx.Error(err)
+			}
+			return
+		}
+		var names []string
+		for _, ent := range entries {
+			name := ent.Name()
+			if ent.IsDir() || pathlib.Ext(name) != ".log" {
+				continue
+			}
+			names = append(names, name)
+		}
+		h.sortLogs(names)
+		for _, name := range names {
+			f, err := os.Open(pathlib.Join(dir, name))
+			if err != nil {
+				_ = ctx.Error(err)
+				return
+			}
+			logs = append(
+				logs,
+				tasking.ExecutionLog{
+					Name:   strings.TrimSuffix(name, ".log"),
+					Reader: f,
+				})
+		}
+	}
+	h.writeLogs(ctx, logs)
+}
+
+// Queue godoc
+// @summary List the task queue.
+// @description List the tasks waiting (ready or postponed) in the queue.
+// @description The tasks are listed in the order considered by the scheduler
+// @description with the (1-based) position and effective (aged) priority.
+// @tags tasks
+// @produce json
+// @success 200 {object} []api.TaskQueued
+// @router /tasks/queue [get]
+func (h TaskHandler) Queue(ctx *gin.Context) {
+	queue := tasking.NewQueue()
+	list, err := queue.Queued(h.DB(ctx))
 	if err != nil {
 		_ = ctx.Error(err)
 		return
 	}
+	resources := []TaskQueued{}
+	for i := range list {
+		m := &list[i]
+		switch m.State {
+		case tasking.Ready,
+			tasking.Postponed:
+		default:
+			continue
+		}
+		r := TaskQueued{}
+		r.With(m)
+		r.Effective = queue.Priority(m)
+		r.Position = len(resources) + 1
+		resources = append(resources, r)
+	}
 
-	h.Status(ctx, http.StatusNoContent)
+	h.Respond(ctx, http.StatusOK, resources)
+}
+
+// Stream godoc
+// @summary Stream task events.
+// @description Stream task state changes and report updates.
+// @description Server-Sent Events (SSE) are sent unless a WebSocket upgrade is requested.
+// @description Events may be filtered by task, task group and application.
+// @tags tasks
+// @produce text/event-stream
+// @success 200 {object} task.Event
+// @router /tasks/stream [get]
+// @param task query int false "Task ID"
+// @param taskGroup query int false "TaskGroup ID"
+// @param application query int false "Application ID"
+func (h TaskHandler) Stream(ctx *gin.Context) {
+	filter := tasking.Filter{}
+	for _, p := range []struct {
+		name string
+		id   *uint
+	}{
+		{name: TaskParam, id: &filter.TaskID},
+		{name: TaskGroupParam, id: &filter.TaskGroupID},
+		{name: ApplicationParam, id: &filter.Application},
+	} {
+		s := ctx.Query(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			_ = ctx.Error(
+				&BadRequestError{
+					Reason: p.name + " must be an ID.",
+				})
+			return
+		}
+		*p.id = uint(n)
+	}
+	subscriber := tasking.Stream.Subscribe(filter)
+	defer tasking.Stream.Unsubscribe(subscriber)
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		h.websocket(ctx, subscriber)
+		return
+	}
+	h.Status(ctx, http.StatusOK)
+	ctx.Stream(func(w io.Writer) (more bool) {
+		select {
+		case <-ctx.Request.Context().Done():
+		case event, open := <-subscriber.Events:
+			if open {
+				ctx.SSEvent(event.Kind, event)
+				more = true
+			}
+		}
+		return
+	})
+}
+
+//
+// websocket sends events over a websocket.
+func (h TaskHandler) websocket(ctx *gin.Context, subscriber *tasking.Subscriber) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case event, open := <-subscriber.Events:
+			if !open {
+				return
+			}
+			err = conn.WriteJSON(event)
+			if err != nil {
+				return
+			}
+		}
+	}
 }
 
 // BucketGet godoc
@@ -406,8 +972,10 @@ func (h TaskHandler) CreateReport(ctx *gin.Context) {
 	result := h.DB(ctx).Create(m)
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
+		return
 	}
 	report.With(m)
+	h.publish(ctx, report)
 
 	h.Respond(ctx, http.StatusCreated, report)
 }
@@ -437,8 +1005,10 @@ func (h TaskHandler) UpdateReport(ctx *gin.Context) {
 	result := db.Updates(h.fields(m))
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
+		return
 	}
 	report.With(m)
+	h.publish(ctx, report)
 
 	h.Respond(ctx, http.StatusOK, report)
 }
@@ -480,7 +1050,9 @@ func (h *TaskHandler) omitted(db *gorm.DB) (out *gorm.DB) {
 		"Terminated",
 		"Canceled",
 		"Error",
+		"Postponed",
 		"Retries",
+		"NextRetry",
 	}...)
 	return
 }
@@ -496,6 +1068,24 @@ type TTL struct {
 	Failed    int `json:"failed,omitempty"`
 }
 
+//
+// RetryPolicy task retry policy.
+type RetryPolicy struct {
+	Limit      *int        `json:"limit,omitempty"`
+	Backoff    int         `json:"backoff,omitempty"`
+	MaxBackoff int         `json:"maxBackoff,omitempty"`
+	Jitter     int         `json:"jitter,omitempty" binding:"min=0,max=100"`
+	Rules      []RetryRule `json:"rules,omitempty"`
+}
+
+//
+// RetryRule determines whether a failure is retryable.
+type RetryRule struct {
+	Reason    string  `json:"reason,omitempty"`
+	ExitCodes []int32 `json:"exitCodes,omitempty"`
+	Retry     bool    `json:"retry"`
+}
+
 //
 // TaskError used in Task.Errors.
 type TaskError struct {
@@ -503,30 +1093,65 @@ type TaskError struct {
 	Description string `json:"description"`
 }
 
+//
+// TaskPostponed used in Task.Postponed.
+type TaskPostponed struct {
+	Rule   string `json:"rule"`
+	TaskID uint   `json:"task,omitempty" yaml:"task,omitempty"`
+}
+
+//
+// TaskPrevious used in Task.Previous.
+// The previous pipeline stage and its result.
+type TaskPrevious struct {
+	TaskID uint        `json:"task"`
+	Addon  string      `json:"addon"`
+	Result interface{} `json:"result,omitempty" yaml:",omitempty" swaggertype:"object"`
+}
+
+//
+// With updates the resource with the model.
+func (r *TaskPrevious) With(m *model.Task) {
+	r.TaskID = m.ID
+	r.Addon = m.Addon
+	if m.Report != nil && m.Report.Result != nil {
+		_ = json.Unmarshal(m.Report.Result, &r.Result)
+	}
+}
+
 //
 // Task REST resource.
 type Task struct {
 	Resource    `yaml:",inline"`
-	Name        string      `json:"name"`
-	Locator     string      `json:"locator,omitempty" yaml:",omitempty"`
-	Priority    int         `json:"priority,omitempty" yaml:",omitempty"`
-	Variant     string      `json:"variant,omitempty" yaml:",omitempty"`
-	Policy      string      `json:"policy,omitempty" yaml:",omitempty"`
-	TTL         *TTL        `json:"ttl,omitempty" yaml:",omitempty"`
-	Addon       string      `json:"addon,omitempty" binding:"required" yaml:",omitempty"`
-	Data        interface{} `json:"data" swaggertype:"object" binding:"required"`
-	Application *Ref        `json:"application,omitempty" yaml:",omitempty"`
-	State       string      `json:"state"`
-	Image       string      `json:"image,omitempty" yaml:",omitempty"`
-	Bucket      *Ref        `json:"bucket,omitempty" yaml:",omitempty"`
-	Purged      bool        `json:"purged,omitempty" yaml:",omitempty"`
-	Started     *time.Time  `json:"started,omitempty" yaml:",omitempty"`
-	Terminated  *time.Time  `json:"terminated,omitempty" yaml:",omitempty"`
-	Errors      []TaskError `json:"errors,omitempty" yaml:",omitempty"`
-	Pod         string      `json:"pod,omitempty" yaml:",omitempty"`
-	Retries     int         `json:"retries,omitempty" yaml:",omitempty"`
-	Canceled    bool        `json:"canceled,omitempty" yaml:",omitempty"`
-	Report      *TaskReport `json:"report,omitempty" yaml:",omitempty"`
+	Name        string         `json:"name"`
+	Locator     string         `json:"locator,omitempty" yaml:",omitempty"`
+	Priority    int            `json:"priority,omitempty" yaml:",omitempty"`
+	Variant     string         `json:"variant,omitempty" yaml:",omitempty"`
+	Policy      string         `json:"policy,omitempty" yaml:",omitempty"`
+	TTL         *TTL           `json:"ttl,omitempty" yaml:",omitempty"`
+	Addon       string         `json:"addon,omitempty" yaml:",omitempty"`
+	Kind        string         `json:"kind,omitempty" yaml:",omitempty"`
+	Stage       int            `json:"stage,omitempty" yaml:",omitempty"`
+	Origin      *Ref           `json:"origin,omitempty" yaml:",omitempty"`
+	Data        interface{}    `json:"data" swaggertype:"object" binding:"required"`
+	Application *Ref           `json:"application,omitempty" yaml:",omitempty"`
+	State       string         `json:"state"`
+	Image       string         `json:"image,omitempty" yaml:",omitempty"`
+	Bucket      *Ref           `json:"bucket,omitempty" yaml:",omitempty"`
+	Purged      bool           `json:"purged,omitempty" yaml:",omitempty"`
+	Started     *time.Time     `json:"started,omitempty" yaml:",omitempty"`
+	Terminated  *time.Time     `json:"terminated,omitempty" yaml:",omitempty"`
+	Errors      []TaskError    `json:"errors,omitempty" yaml:",omitempty"`
+	Postponed   *TaskPostponed `json:"postponed,omitempty" yaml:",omitempty"`
+	Pod         string         `json:"pod,omitempty" yaml:",omitempty"`
+	Retries     int            `json:"retries,omitempty" yaml:",omitempty"`
+	Deadline    int            `json:"deadline,omitempty" yaml:",omitempty"`
+	RetryPolicy *RetryPolicy   `json:"retryPolicy,omitempty" yaml:"retryPolicy,omitempty"`
+	NextRetry   *time.Time     `json:"nextRetry,omitempty" yaml:"nextRetry,omitempty"`
+	Canceled    bool           `json:"canceled,omitempty" yaml:",omitempty"`
+	Report      *TaskReport    `json:"report,omitempty" yaml:",omitempty"`
+	DependsOn   []Ref          `json:"dependsOn,omitempty" yaml:",omitempty"`
+	Previous    *TaskPrevious  `json:"previous,omitempty" yaml:",omitempty"`
 }
 
 //
@@ -536,6 +1161,9 @@ func (r *Task) With(m *model.Task) {
 	r.Name = m.Name
 	r.Image = m.Image
 	r.Addon = m.Addon
+	r.Kind = m.Kind
+	r.Stage = m.Stage
+	r.Origin = r.refPtr(m.OriginID, m.Origin)
 	r.Locator = m.Locator
 	r.Priority = m.Priority
 	r.Policy = m.Policy
@@ -547,6 +1175,11 @@ func (r *Task) With(m *model.Task) {
 	r.Terminated = m.Terminated
 	r.Pod = m.Pod
 	r.Retries = m.Retries
+	r.Deadline = m.Deadline
+	r.NextRetry = m.NextRetry
+	if m.RetryPolicy != nil {
+		_ = json.Unmarshal(m.RetryPolicy, &r.RetryPolicy)
+	}
 	r.Canceled = m.Canceled
 	_ = json.Unmarshal(m.Data, &r.Data)
 	if m.Report != nil {
@@ -560,6 +1193,15 @@ func (r *Task) With(m *model.Task) {
 	if m.Errors != nil {
 		_ = json.Unmarshal(m.Errors, &r.Errors)
 	}
+	if m.Postponed != nil {
+		_ = json.Unmarshal(m.Postponed, &r.Postponed)
+	}
+	r.DependsOn = []Ref{}
+	for i := range m.DependsOn {
+		dep := Ref{}
+		dep.With(m.DependsOn[i].ID, m.DependsOn[i].Name)
+		r.DependsOn = append(r.DependsOn, dep)
+	}
 }
 
 //
@@ -568,10 +1210,13 @@ func (r *Task) Model() (m *model.Task) {
 	m = &model.Task{
 		Name:          r.Name,
 		Addon:         r.Addon,
+		Kind:          r.Kind,
+		Stage:         r.Stage,
 		Locator:       r.Locator,
 		Variant:       r.Variant,
 		Priority:      r.Priority,
 		Policy:        r.Policy,
+		Deadline:      r.Deadline,
 		State:         r.State,
 		ApplicationID: r.idPtr(r.Application),
 	}
@@ -580,9 +1225,158 @@ func (r *Task) Model() (m *model.Task) {
 	if r.TTL != nil {
 		m.TTL, _ = json.Marshal(r.TTL)
 	}
+	if r.RetryPolicy != nil {
+		m.RetryPolicy, _ = json.Marshal(r.RetryPolicy)
+	}
+	for _, ref := range r.DependsOn {
+		m.DependsOn = append(
+			m.DependsOn,
+			model.Task{
+				Model: model.Model{
+					ID: ref.ID,
+				},
+			})
+	}
+	return
+}
+
+//
+// TaskQueued REST resource.
+// A task waiting in the queue.
+type TaskQueued struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Addon       string `json:"addon,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	State       string `json:"state"`
+	Priority    int    `json:"priority,omitempty"`
+	Effective   int    `json:"effectivePriority"`
+	Position    int    `json:"position"`
+	Application *Ref   `json:"application,omitempty"`
+	TaskGroup   *Ref   `json:"taskGroup,omitempty"`
+	CreateUser  string `json:"createUser"`
+}
+
+//
+// With updates the resource with the model.
+func (r *TaskQueued) With(m *model.Task) {
+	r.ID = m.ID
+	r.Name = m.Name
+	r.Addon = m.Addon
+	r.Kind = m.Kind
+	r.State = m.State
+	r.Priority = m.Priority
+	r.CreateUser = m.CreateUser
+	if m.ApplicationID != nil {
+		r.Application = &Ref{ID: *m.ApplicationID}
+	}
+	if m.TaskGroupID != nil {
+		r.TaskGroup = &Ref{ID: *m.TaskGroupID}
+	}
+}
+
+//
+// submitted prepares a submitted task.
+// The addon is selected (by kind) and the data validated.
+// Returns BadRequestError when no addon is matched.
+func (h *BaseHandler) submitted(ctx *gin.Context, m *model.Task) (err error) {
+	rt := tasking.Task{Task: m}
+	err = rt.Submit(h.DB(ctx), h.Client(ctx))
+	if errors.Is(err, &tasking.AddonNotMatched{}) {
+		err = &BadRequestError{err.Error()}
+	}
 	return
 }
 
+//
+// sortLogs sorts the captured logs named: <name>.<retries>.log
+// by attempt (retries). The attempt is numeric.
+func (h TaskHandler) sortLogs(names []string) {
+	attempt := func(name string) (n int) {
+		name = strings.TrimSuffix(name, ".log")
+		n, _ = strconv.Atoi(strings.TrimPrefix(pathlib.Ext(name), "."))
+		return
+	}
+	sort.SliceStable(
+		names,
+		func(i, j int) bool {
+			return attempt(names[i]) < attempt(names[j])
+		})
+}
+
+//
+// publish the report to the task event stream.
+func (h TaskHandler) publish(ctx *gin.Context, report *TaskReport) {
+	err := tasking.Stream.PublishTask(
+		h.DB(ctx),
+		tasking.Event{
+			Kind:   tasking.EventReport,
+			TaskID: report.TaskID,
+			Report: report,
+		})
+	if err != nil {
+		log.Error(err, "")
+	}
+}
+
+//
+// writeLogs writes (and closes) the logs.
+// Each log is preceded by a header when multiple logs are written.
+// The response is flushed as content is written to support following.
+func (h TaskHandler) writeLogs(ctx *gin.Context, logs []tasking.ExecutionLog) {
+	defer func() {
+		for _, entry := range logs {
+			_ = entry.Reader.Close()
+		}
+	}()
+	ctx.Header(ContentType, binding.MIMEPlain)
+	h.Status(ctx, http.StatusOK)
+	writer := ctx.Writer
+	bfr := make([]byte, 4096)
+	for _, entry := range logs {
+		if len(logs) > 1 {
+			_, _ = fmt.Fprintf(writer, "==> %s <==\n", entry.Name)
+		}
+		for {
+			n, err := entry.Reader.Read(bfr)
+			if n > 0 {
+				_, wErr := writer.Write(bfr[:n])
+				if wErr != nil {
+					return
+				}
+				writer.Flush()
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+}
+
+//
+// TaskEvent REST resource.
+type TaskEvent struct {
+	Resource `yaml:",inline"`
+	Previous string `json:"previous,omitempty" yaml:",omitempty"`
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty" yaml:",omitempty"`
+	Pod      string `json:"pod,omitempty" yaml:",omitempty"`
+	Retry    int    `json:"retry,omitempty" yaml:",omitempty"`
+	TaskID   uint   `json:"task"`
+}
+
+//
+// With updates the resource with the model.
+func (r *TaskEvent) With(m *model.TaskEvent) {
+	r.Resource.With(&m.Model)
+	r.Previous = m.Previous
+	r.State = m.State
+	r.Reason = m.Reason
+	r.Pod = m.Pod
+	r.Retry = m.Retry
+	r.TaskID = m.TaskID
+}
+
 //
 // TaskReport REST resource.
 type TaskReport struct {
@@ -640,3 +1434,21 @@ func (r *TaskReport) Model() (m *model.TaskReport) {
 
 	return
 }
+
+//
+// TaskBulkResult REST resource.
+// The result of a bulk operation on a task.
+type TaskBulkResult struct {
+	ID    uint   `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+//
+// TaskRerun REST resource.
+// Options used to rerun a task.
+type TaskRerun struct {
+	// Data overrides the task data.
+	Data interface{} `json:"data,omitempty" swaggertype:"object"`
+	// State of the new task. (Created|Ready). Default: Ready.
+	State string `json:"state,omitempty"`
+}
@@ -3,11 +3,14 @@ package api
 import (
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	qf "github.com/konveyor/tackle2-hub/api/filter"
 	"github.com/konveyor/tackle2-hub/model"
 	tasking "github.com/konveyor/tackle2-hub/task"
 	"gorm.io/gorm/clause"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"net/http"
+	"strings"
+	"time"
 )
 
 //
@@ -18,6 +21,8 @@ const (
 	TaskGroupBucketRoot        = TaskGroupRoot + "/bucket"
 	TaskGroupBucketContentRoot = TaskGroupBucketRoot + "/*" + Wildcard
 	TaskGroupSubmitRoot        = TaskGroupRoot + "/submit"
+	TaskGroupCancelRoot        = TaskGroupRoot + "/cancel"
+	TaskGroupResubmitRoot      = TaskGroupRoot + "/resubmit"
 )
 
 //
@@ -37,6 +42,8 @@ func (h TaskGroupHandler) AddRoutes(e *gin.Engine) {
 	routeGroup.PUT(TaskGroupRoot, h.Update)
 	routeGroup.GET(TaskGroupRoot, h.Get)
 	routeGroup.PUT(TaskGroupSubmitRoot, h.Submit, h.Update)
+	routeGroup.PUT(TaskGroupCancelRoot, h.Cancel)
+	routeGroup.PUT(TaskGroupResubmitRoot, h.Resubmit)
 	routeGroup.DELETE(TaskGroupRoot, h.Delete)
 	// Bucket
 	routeGroup = e.Group("/")
@@ -60,6 +67,7 @@ func (h TaskGroupHandler) Get(ctx *gin.Context) {
 	m := &model.TaskGroup{}
 	id := h.pk(ctx)
 	db := h.DB(ctx).Preload(clause.Associations)
+	db = db.Preload("Tasks.Report")
 	result := db.First(m, id)
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
@@ -76,20 +84,44 @@ func (h TaskGroupHandler) Get(ctx *gin.Context) {
 // @description List all task groups.
 // @tags taskgroups
 // @produce json
+// @description filters:
+// @description - id
+// @description - name
+// @description - addon
+// @description - state
+// @description - status (computed state)
 // @success 200 {object} []api.TaskGroup
 // @router /taskgroups [get]
 func (h TaskGroupHandler) List(ctx *gin.Context) {
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "id", Kind: qf.LITERAL},
+			{Field: "name", Kind: qf.STRING},
+			{Field: "addon", Kind: qf.STRING},
+			{Field: "state", Kind: qf.STRING},
+			{Field: "status", Kind: qf.STRING},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
 	var list []model.TaskGroup
 	db := h.DB(ctx).Preload(clause.Associations)
+	db = db.Preload("Tasks.Report")
+	db = filter.Where(db, "-Status")
 	result := db.Find(&list)
 	if result.Error != nil {
 		_ = ctx.Error(result.Error)
 		return
 	}
+	status, filtered := filter.Field("status")
 	resources := []TaskGroup{}
 	for i := range list {
 		r := TaskGroup{}
 		r.With(&list[i])
+		if filtered && !r.Status.Match(status) {
+			continue
+		}
 		resources = append(resources, r)
 	}
 
@@ -125,6 +157,11 @@ func (h TaskGroupHandler) Create(ctx *gin.Context) {
 		if err != nil {
 			return
 		}
+		err = h.tasksSubmitted(ctx, m)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
 	default:
 		h.Respond(ctx,
 			http.StatusBadRequest,
@@ -181,6 +218,11 @@ func (h TaskGroupHandler) Update(ctx *gin.Context) {
 		if err != nil {
 			return
 		}
+		err = h.tasksSubmitted(ctx, m)
+		if err != nil {
+			_ = ctx.Error(err)
+			return
+		}
 	default:
 		h.Respond(ctx,
 			http.StatusBadRequest,
@@ -276,6 +318,81 @@ func (h TaskGroupHandler) Submit(ctx *gin.Context) {
 	ctx.Next()
 }
 
+//
+// submitted prepares the (propagated) tasks.
+func (h TaskGroupHandler) tasksSubmitted(ctx *gin.Context, m *model.TaskGroup) (err error) {
+	for i := range m.Tasks {
+		err = h.submitted(ctx, &m.Tasks[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Cancel godoc
+// @summary Cancel the (unterminated) tasks in a task group.
+// @description Cancel the (unterminated) tasks in a task group.
+// @tags taskgroups
+// @produce json
+// @success 200 {object} []api.TaskBulkResult
+// @router /taskgroups/{id}/cancel [put]
+// @param id path string true "TaskGroup ID"
+func (h TaskGroupHandler) Cancel(ctx *gin.Context) {
+	h.bulk(
+		ctx,
+		[]string{
+			tasking.Created,
+			tasking.Ready,
+			tasking.Postponed,
+			tasking.Pending,
+			tasking.Running,
+		},
+		h.cancelTask)
+}
+
+// Resubmit godoc
+// @summary Resubmit the failed tasks in a task group.
+// @description Resubmit the failed (including timed out and canceled) tasks in a task group.
+// @tags taskgroups
+// @produce json
+// @success 200 {object} []api.TaskBulkResult
+// @router /taskgroups/{id}/resubmit [put]
+// @param id path string true "TaskGroup ID"
+func (h TaskGroupHandler) Resubmit(ctx *gin.Context) {
+	h.bulk(
+		ctx,
+		[]string{
+			tasking.Failed,
+			tasking.TimedOut,
+			tasking.Canceled,
+		},
+		h.retryTask)
+}
+
+//
+// bulk applies the operation to the tasks in the group
+// with the specified states.
+func (h TaskGroupHandler) bulk(ctx *gin.Context, states []string, operation TaskOperation) {
+	m := &model.TaskGroup{}
+	id := h.pk(ctx)
+	err := h.DB(ctx).First(m, id).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	var list []model.Task
+	db := h.DB(ctx).Where("TaskGroupID", id)
+	db = db.Where("State IN ?", states)
+	err = db.Find(&list).Error
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	h.Respond(ctx, http.StatusOK, h.apply(ctx, list, operation))
+}
+
 // BucketGet godoc
 // @summary Get bucket content by ID and path.
 // @description Get bucket content by ID and path.
@@ -355,12 +472,15 @@ func (h TaskGroupHandler) BucketDelete(ctx *gin.Context) {
 // TaskGroup REST resource.
 type TaskGroup struct {
 	Resource
-	Name   string      `json:"name"`
-	Addon  string      `json:"addon"`
-	Data   interface{} `json:"data" swaggertype:"object" binding:"required"`
-	Bucket *Ref        `json:"bucket,omitempty"`
-	State  string      `json:"state"`
-	Tasks  []Task      `json:"tasks"`
+	Name   string           `json:"name"`
+	Addon  string           `json:"addon"`
+	Kind   string           `json:"kind,omitempty"`
+	Data   interface{}      `json:"data" swaggertype:"object" binding:"required"`
+	Stages []TaskStage      `json:"stages,omitempty"`
+	Bucket *Ref             `json:"bucket,omitempty"`
+	State  string           `json:"state"`
+	Tasks  []Task           `json:"tasks"`
+	Status *TaskGroupStatus `json:"status,omitempty"`
 }
 
 //
@@ -369,10 +489,13 @@ func (r *TaskGroup) With(m *model.TaskGroup) {
 	r.Resource.With(&m.Model)
 	r.Name = m.Name
 	r.Addon = m.Addon
+	r.Kind = m.Kind
 	r.State = m.State
 	r.Bucket = r.refPtr(m.BucketID, m.Bucket)
 	r.Tasks = []Task{}
+	r.Stages = nil
 	_ = json.Unmarshal(m.Data, &r.Data)
+	_ = json.Unmarshal(m.Stages, &r.Stages)
 	switch m.State {
 	case "", tasking.Created:
 		_ = json.Unmarshal(m.List, &r.Tasks)
@@ -385,6 +508,8 @@ func (r *TaskGroup) With(m *model.TaskGroup) {
 				member)
 		}
 	}
+	r.Status = &TaskGroupStatus{}
+	r.Status.With(m, r.Stages)
 }
 
 //
@@ -393,11 +518,15 @@ func (r *TaskGroup) Model() (m *model.TaskGroup) {
 	m = &model.TaskGroup{
 		Name:  r.Name,
 		Addon: r.Addon,
+		Kind:  r.Kind,
 		State: r.State,
 	}
 	m.ID = r.ID
 	m.Data, _ = json.Marshal(r.Data)
 	m.List, _ = json.Marshal(r.Tasks)
+	if len(r.Stages) > 0 {
+		m.Stages, _ = json.Marshal(r.Stages)
+	}
 	if r.Bucket != nil {
 		m.BucketID = &r.Bucket.ID
 	}
@@ -409,3 +538,146 @@ func (r *TaskGroup) Model() (m *model.TaskGroup) {
 	}
 	return
 }
+
+//
+// TaskStage REST resource.
+// A pipeline stage.
+type TaskStage struct {
+	Name  string      `json:"name" binding:"required"`
+	Addon string      `json:"addon,omitempty"`
+	Kind  string      `json:"kind,omitempty"`
+	Data  interface{} `json:"data,omitempty" swaggertype:"object"`
+}
+
+//
+// TaskGroupStatus the (computed) task group status.
+type TaskGroupStatus struct {
+	State      string         `json:"state"`
+	Tasks      map[string]int `json:"tasks"`
+	Completed  int            `json:"completed"`
+	Started    *time.Time     `json:"started,omitempty"`
+	Terminated *time.Time     `json:"terminated,omitempty"`
+	Stages     []StageStatus  `json:"stages,omitempty"`
+}
+
+//
+// With updates the status with the model.
+// Completed is the (percent) completion of the tasks.
+// Terminated is reported when all tasks have terminated.
+func (r *TaskGroupStatus) With(m *model.TaskGroup, stages []TaskStage) {
+	switch m.State {
+	case "", tasking.Created:
+		r.State = tasking.Created
+		r.Tasks = make(map[string]int)
+		return
+	}
+	r.State, r.Tasks = r.rollup(m.Tasks)
+	completed := float64(0)
+	for i := range m.Tasks {
+		task := &m.Tasks[i]
+		switch task.State {
+		case tasking.Succeeded,
+			tasking.Failed,
+			tasking.TimedOut,
+			tasking.Canceled:
+			completed += 1
+			if r.Terminated == nil || (task.Terminated != nil && task.Terminated.After(*r.Terminated)) {
+				r.Terminated = task.Terminated
+			}
+		default:
+			report := task.Report
+			if report != nil && report.Total > 0 {
+				completed += float64(report.Completed) / float64(report.Total)
+			}
+		}
+		if task.Started != nil {
+			if r.Started == nil || task.Started.Before(*r.Started) {
+				r.Started = task.Started
+			}
+		}
+	}
+	if len(m.Tasks) > 0 {
+		r.Completed = int(completed * 100 / float64(len(m.Tasks)))
+	}
+	switch r.State {
+	case tasking.Succeeded,
+		tasking.Failed,
+		tasking.Canceled:
+	default:
+		r.Terminated = nil
+	}
+	r.Stages = nil
+	for i := range stages {
+		var tasks []model.Task
+		for _, task := range m.Tasks {
+			if task.Stage == i+1 {
+				tasks = append(tasks, task)
+			}
+		}
+		status := StageStatus{Name: stages[i].Name}
+		status.State, status.Tasks = r.rollup(tasks)
+		r.Stages = append(r.Stages, status)
+	}
+}
+
+//
+// Match returns true when the state is matched by the filter.
+func (r *TaskGroupStatus) Match(f qf.Field) (matched bool) {
+	for _, v := range f.Value.ByKind(qf.LITERAL, qf.STRING) {
+		if strings.EqualFold(v.Value, r.State) {
+			matched = true
+			break
+		}
+	}
+	if f.Operator.Value == "!=" {
+		matched = !matched
+	}
+	return
+}
+
+//
+// rollup returns the aggregate state and the number
+// of tasks by state. When all tasks have terminated, the
+// state is Failed when any task failed (or timed out),
+// Succeeded when any task succeeded, else Canceled.
+func (r *TaskGroupStatus) rollup(tasks []model.Task) (state string, counts map[string]int) {
+	counts = make(map[string]int)
+	for _, task := range tasks {
+		counts[task.State]++
+	}
+	terminated := counts[tasking.Succeeded] +
+		counts[tasking.Failed] +
+		counts[tasking.TimedOut] +
+		counts[tasking.Canceled]
+	switch {
+	case len(tasks) == 0:
+	case counts[tasking.Succeeded] == len(tasks):
+		state = tasking.Succeeded
+	case terminated == len(tasks):
+		switch {
+		case counts[tasking.Failed]+counts[tasking.TimedOut] > 0:
+			state = tasking.Failed
+		case counts[tasking.Succeeded] > 0:
+			state = tasking.Succeeded
+		default:
+			state = tasking.Canceled
+		}
+	case terminated > 0,
+		counts[tasking.Pending] > 0,
+		counts[tasking.Running] > 0:
+		state = tasking.Running
+	case counts[tasking.Created] == len(tasks):
+		state = tasking.Created
+	default:
+		state = tasking.Ready
+	}
+	return
+}
+
+//
+// StageStatus the (computed) pipeline stage status.
+type StageStatus struct {
+	Name  string         `json:"name"`
+	State string         `json:"state"`
+	Tasks map[string]int `json:"tasks"`
+}
@@ -0,0 +1,577 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	liberr "github.com/jortel/go-utils/error"
+	qf "github.com/konveyor/tackle2-hub/api/filter"
+	"github.com/konveyor/tackle2-hub/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// Routes
+const (
+	WaiversRoot = "/waivers"
+	WaiverRoot  = WaiversRoot + "/:" + ID
+)
+
+//
+// Params
+const (
+	Waived = "waived"
+)
+
+//
+// WaiverHandler handles waiver routes.
+type WaiverHandler struct {
+	BaseHandler
+}
+
+//
+// AddRoutes adds routes.
+func (h WaiverHandler) AddRoutes(e *gin.Engine) {
+	routeGroup := e.Group("/")
+	routeGroup.Use(Required("waivers"))
+	routeGroup.GET(WaiversRoot, h.List)
+	routeGroup.GET(WaiversRoot+"/", h.List)
+	routeGroup.POST(WaiversRoot, h.Create)
+	routeGroup.GET(WaiverRoot, h.Get)
+	routeGroup.PUT(WaiverRoot, h.Update)
+	routeGroup.DELETE(WaiverRoot, h.Delete)
+}
+
+// Get godoc
+// @summary Get a waiver by ID.
+// @description Get a waiver by ID.
+// @tags waivers
+// @produce json
+// @success 200 {object} api.Waiver
+// @router /waivers/{id} [get]
+// @param id path string true "Waiver ID"
+func (h WaiverHandler) Get(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Waiver{}
+	db := h.preLoad(h.DB(ctx), clause.Associations)
+	result := db.First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	r := Waiver{}
+	r.With(m)
+
+	h.Respond(ctx, http.StatusOK, r)
+}
+
+// List godoc
+// @summary List all waivers.
+// @description List all waivers.
+// @description filters:
+// @description - ruleset
+// @description - rule
+// @description - application.id
+// @tags waivers
+// @produce json
+// @success 200 {object} []api.Waiver
+// @router /waivers [get]
+func (h WaiverHandler) List(ctx *gin.Context) {
+	filter, err := qf.New(ctx,
+		[]qf.Assert{
+			{Field: "ruleset", Kind: qf.STRING},
+			{Field: "rule", Kind: qf.STRING},
+			{Field: "application.id", Kind: qf.LITERAL},
+		})
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	db := h.preLoad(h.DB(ctx), clause.Associations)
+	db = filter.Where(db)
+	if f, found := filter.Field("application.id"); found {
+		f = f.As("ApplicationID")
+		db = f.Where(db)
+	}
+	var list []model.Waiver
+	result := db.Find(&list)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	resources := []Waiver{}
+	for i := range list {
+		r := Waiver{}
+		r.With(&list[i])
+		resources = append(resources, r)
+	}
+
+	h.Respond(ctx, http.StatusOK, resources)
+}
+
+// Create godoc
+// @summary Create a waiver.
+// @description Create a waiver.
+// @description The author is the current user.
+// @tags waivers
+// @accept json
+// @produce json
+// @success 201 {object} api.Waiver
+// @router /waivers [post]
+// @param waiver body api.Waiver true "Waiver data"
+func (h WaiverHandler) Create(ctx *gin.Context) {
+	r := &Waiver{}
+	err := h.Bind(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = r.Validate()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m := r.Model()
+	m.CreateUser = h.BaseHandler.CurrentUser(ctx)
+	result := h.DB(ctx).Create(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	r.With(m)
+
+	h.Respond(ctx, http.StatusCreated, r)
+}
+
+// Delete godoc
+// @summary Delete a waiver.
+// @description Delete a waiver.
+// @tags waivers
+// @success 204
+// @router /waivers/{id} [delete]
+// @param id path string true "Waiver ID"
+func (h WaiverHandler) Delete(ctx *gin.Context) {
+	id := h.pk(ctx)
+	m := &model.Waiver{}
+	result := h.DB(ctx).First(m, id)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+	result = h.DB(ctx).Delete(m)
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+// Update godoc
+// @summary Update a waiver.
+// @description Update a waiver.
+// @tags waivers
+// @accept json
+// @success 204
+// @router /waivers/{id} [put]
+// @param id path string true "Waiver ID"
+// @param waiver body api.Waiver true "Waiver data"
+func (h WaiverHandler) Update(ctx *gin.Context) {
+	id := h.pk(ctx)
+	r := &Waiver{}
+	err := h.Bind(ctx, r)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	err = r.Validate()
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	m := r.Model()
+	m.ID = id
+	m.UpdateUser = h.BaseHandler.CurrentUser(ctx)
+	db := h.DB(ctx).Model(m)
+	db = db.Omit(clause.Associations)
+	result := db.Updates(h.fields(m))
+	if result.Error != nil {
+		_ = ctx.Error(result.Error)
+		return
+	}
+
+	h.Status(ctx, http.StatusNoContent)
+}
+
+//
+// Waiver REST resource.
+type Waiver struct {
+	Resource      `yaml:",inline"`
+	RuleSet       string     `json:"ruleset" binding:"required"`
+	Rule          string     `json:"rule" binding:"required"`
+	Application   *Ref       `json:"application,omitempty"`
+	File          string     `json:"file,omitempty"`
+	Fingerprint   string     `json:"fingerprint,omitempty"`
+	Justification string     `json:"justification" binding:"required"`
+	Expiration    *time.Time `json:"expiration,omitempty"`
+	Expired       bool       `json:"expired"`
+}
+
+//
+// With updates the resource with the model.
+func (r *Waiver) With(m *model.Waiver) {
+	r.Resource.With(&m.Model)
+	r.RuleSet = m.RuleSet
+	r.Rule = m.Rule
+	r.Application = r.refPtr(m.ApplicationID, m.Application)
+	r.File = m.File
+	r.Fingerprint = m.Fingerprint
+	r.Justification = m.Justification
+	r.Expiration = m.Expiration
+	r.Expired = m.Expiration != nil && !m.Expiration.After(time.Now())
+}
+
+//
+// Model builds a model.
+func (r *Waiver) Model() (m *model.Waiver) {
+	m = &model.Waiver{
+		RuleSet:       r.RuleSet,
+		Rule:          r.Rule,
+		ApplicationID: r.idPtr(r.Application),
+		File:          r.File,
+		Fingerprint:   r.Fingerprint,
+		Justification: r.Justification,
+		Expiration:    r.Expiration,
+	}
+	m.ID = r.ID
+	return
+}
+
+//
+// Validate the resource.
+func (r *Waiver) Validate() (err error) {
+	_, err = path.Match(r.File, "")
+	if err != nil {
+		err = &BadRequestError{
+			Reason: "file: " + err.Error(),
+		}
+		return
+	}
+	return
+}
+
+//
+// Fingerprint returns the incident fingerprint.
+// Based on the file and message so that the fingerprint
+// is stable across analyses as lines are added or removed.
+func Fingerprint(m *model.Incident) (fp string) {
+	h := sha256.New()
+	_, _ = h.Write([]byte(m.File))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(m.Message))
+	fp = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+//
+// WaivedBatch the number of IDs (bound variables) per
+// statement (or condition).
+const WaivedBatch = 500
+
+//
+// WaivedIssues issues and incidents waived within analyses.
+// Issues waived by (issue) waivers are excluded using subqueries
+// built from the waiver predicates. Incidents waived by file or
+// fingerprint are matched in memory and excluded by ID.
+type WaivedIssues struct {
+	// Effort waived by analysis ID.
+	Effort map[uint]int
+	// issues (ID) with all incidents waived.
+	issues map[uint]bool
+	// incidents (ID) waived within issues partially waived.
+	incidents map[uint]bool
+	// matched issues (ID) with all incidents waived by
+	// file or fingerprint.
+	matched []uint
+	// rules predicate of the (issue) waivers.
+	rules *gorm.DB
+	// analyses (ID) searched.
+	analyses interface{}
+	// db used to build the queries.
+	db *gorm.DB
+}
+
+//
+// With finds the issues and incidents waived within the analyses.
+// The analyses may be an ID, list of IDs or a (sub)query.
+// Expired waivers are ignored. An issue is waived when all
+// of the incidents are waived.
+func (r *WaivedIssues) With(db *gorm.DB, analyses interface{}) (err error) {
+	r.Effort = make(map[uint]int)
+	r.issues = make(map[uint]bool)
+	r.incidents = make(map[uint]bool)
+	r.analyses = analyses
+	r.db = db.Session(&gorm.Session{NewDB: true})
+	var waivers []model.Waiver
+	q := r.db.Where("Expiration IS NULL OR Expiration > ?", time.Now())
+	err = q.Find(&waivers).Error
+	if err != nil || len(waivers) == 0 {
+		return
+	}
+	type M struct {
+		model.Issue
+		ApplicationID uint
+	}
+	rules := r.db.Session(&gorm.Session{NewDB: true})
+	for i := range waivers {
+		w := &waivers[i]
+		rules = rules.Or("i.RuleSet = ? AND i.Rule = ?", w.RuleSet, w.Rule)
+		if w.File != "" || w.Fingerprint != "" {
+			continue
+		}
+		if w.ApplicationID != nil {
+			r.rule("i.RuleSet = ? AND i.Rule = ? AND a.ApplicationID = ?", w.RuleSet, w.Rule, *w.ApplicationID)
+		} else {
+			r.rule("i.RuleSet = ? AND i.Rule = ?", w.RuleSet, w.Rule)
+		}
+	}
+	var issues []M
+	q = r.issueQuery()
+	q = q.Select(
+		"i.ID",
+		"i.RuleSet",
+		"i.Rule",
+		"i.Effort",
+		"i.AnalysisID",
+		"a.ApplicationID")
+	q = q.Where(rules)
+	err = q.Find(&issues).Error
+	if err != nil || len(issues) == 0 {
+		return
+	}
+	var whole []*M
+	partial := make(map[uint][]*model.Waiver)
+	for i := range issues {
+		issue := &issues[i]
+		var matched []*model.Waiver
+		for j := range waivers {
+			w := &waivers[j]
+			if w.RuleSet != issue.RuleSet || w.Rule != issue.Rule {
+				continue
+			}
+			if w.ApplicationID != nil && *w.ApplicationID != issue.ApplicationID {
+				continue
+			}
+			if w.File == "" && w.Fingerprint == "" {
+				whole = append(whole, issue)
+				matched = nil
+				break
+			}
+			matched = append(matched, w)
+		}
+		if len(matched) > 0 {
+			partial[issue.ID] = matched
+		}
+	}
+	for _, issue := range whole {
+		r.issues[issue.ID] = true
+	}
+	byID := make(map[uint]*M)
+	var ids []uint
+	for i := range issues {
+		issue := &issues[i]
+		if _, found := partial[issue.ID]; found {
+			byID[issue.ID] = issue
+			ids = append(ids, issue.ID)
+		}
+	}
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > WaivedBatch {
+			n = WaivedBatch
+		}
+		var incidents []model.Incident
+		q = r.db.Select("ID", "File", "Message", "IssueID")
+		q = q.Where("IssueID IN ?", ids[:n])
+		err = q.Find(&incidents).Error
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		byIssue := make(map[uint][]*model.Incident)
+		for i := range incidents {
+			m := &incidents[i]
+			byIssue[m.IssueID] = append(byIssue[m.IssueID], m)
+		}
+		for _, id := range ids[:n] {
+			issue := byID[id]
+			waived := 0
+			for _, incident := range byIssue[id] {
+				for _, w := range partial[id] {
+					if r.match(w, incident) {
+						r.incidents[incident.ID] = true
+						r.Effort[issue.AnalysisID] += issue.Effort
+						waived++
+						break
+					}
+				}
+			}
+			if waived > 0 && waived == len(byIssue[id]) {
+				r.issues[id] = true
+				r.matched = append(r.matched, id)
+			}
+		}
+		ids = ids[n:]
+	}
+	if len(whole) == 0 {
+		return
+	}
+	var counts []struct {
+		IssueID uint
+		Count   int
+	}
+	q = r.db.Model(&model.Incident{})
+	q = q.Select("IssueID", "COUNT(ID) Count")
+	q = q.Where("IssueID IN (?)", r.issueQuery().Select("i.ID").Where(r.rules))
+	q = q.Group("IssueID")
+	err = q.Find(&counts).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	incidents := make(map[uint]int)
+	for _, m := range counts {
+		incidents[m.IssueID] = m.Count
+	}
+	for _, issue := range whole {
+		r.Effort[issue.AnalysisID] += issue.Effort * incidents[issue.ID]
+	}
+	return
+}
+
+//
+// Issues returns the (sub)query of waived issue IDs.
+// Returns nil when no issues are waived.
+func (r *WaivedIssues) Issues() (q *gorm.DB) {
+	if len(r.issues) == 0 {
+		return
+	}
+	waived := r.db.Session(&gorm.Session{NewDB: true})
+	if r.rules != nil {
+		waived = waived.Or(r.rules)
+	}
+	for _, ids := range r.batched(r.matched) {
+		waived = waived.Or("i.ID IN ?", ids)
+	}
+	q = r.issueQuery()
+	q = q.Select("i.ID")
+	q = q.Where(waived)
+	return
+}
+
+//
+// Incidents returns the (sub)query of waived incident IDs.
+// Includes the incidents of waived issues.
+// Returns nil when no incidents are waived.
+func (r *WaivedIssues) Incidents() (q *gorm.DB) {
+	if len(r.issues) == 0 && len(r.incidents) == 0 {
+		return
+	}
+	waived := r.db.Session(&gorm.Session{NewDB: true})
+	issues := r.Issues()
+	if issues != nil {
+		waived = waived.Or("IssueID IN (?)", issues)
+	}
+	ids := make([]uint, 0, len(r.incidents))
+	for id := range r.incidents {
+		ids = append(ids, id)
+	}
+	for _, batch := range r.batched(ids) {
+		waived = waived.Or("ID IN ?", batch)
+	}
+	q = r.db.Model(&model.Incident{})
+	q = q.Select("ID")
+	q = q.Where(waived)
+	return
+}
+
+//
+// Waived returns true when the issue is waived.
+func (r *WaivedIssues) Waived(id uint) (waived bool) {
+	waived = r.issues[id]
+	return
+}
+
+//
+// IncidentWaived returns true when the incident is waived.
+func (r *WaivedIssues) IncidentWaived(issue, incident uint) (waived bool) {
+	waived = r.issues[issue] || r.incidents[incident]
+	return
+}
+
+//
+// rule adds an (issue) waiver predicate.
+func (r *WaivedIssues) rule(query string, args ...interface{}) {
+	if r.rules == nil {
+		r.rules = r.db.Session(&gorm.Session{NewDB: true})
+	}
+	r.rules = r.rules.Or(query, args...)
+}
+
+//
+// issueQuery returns a query of the issues (i) joined with
+// the analysis (a) within the analyses.
+func (r *WaivedIssues) issueQuery() (q *gorm.DB) {
+	q = r.db.Table("Issue i")
+	q = q.Joins(",Analysis a")
+	q = q.Where("a.ID = i.AnalysisID")
+	q = q.Where("a.ID IN (?)", r.analyses)
+	return
+}
+
+//
+// batched returns the IDs split into batches.
+func (r *WaivedIssues) batched(ids []uint) (batches [][]uint) {
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > WaivedBatch {
+			n = WaivedBatch
+		}
+		batches = append(batches, ids[:n])
+		ids = ids[n:]
+	}
+	return
+}
+
+//
+// match returns true when the waiver matches the incident.
+// The file glob is matched with the path and the base name
+// (when the glob contains no directory).
+func (r *WaivedIssues) match(w *model.Waiver, m *model.Incident) (matched bool) {
+	if w.Fingerprint != "" && w.Fingerprint != Fingerprint(m) {
+		return
+	}
+	if w.File != "" {
+		matched, _ = path.Match(w.File, m.File)
+		if !matched && !strings.Contains(w.File, "/") {
+			matched, _ = path.Match(w.File, path.Base(m.File))
+		}
+		return
+	}
+	matched = true
+	return
+}
+
+//
+// waivedIncluded returns true when waived issues are
+// requested to be included (and flagged).
+func (h *BaseHandler) waivedIncluded(ctx *gin.Context) (included bool) {
+	included, _ = strconv.ParseBool(ctx.Query(Waived))
+	return
+}
@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"errors"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/onsi/gomega"
+	"gorm.io/gorm"
 	"testing"
 )
 
@@ -76,6 +78,74 @@ func TestNotValid(t *testing.T) {
 	g.Expect(err != nil).To(gomega.BeTrue())
 }
 
+type _TestValidator struct {
+	valid bool
+}
+
+func (v *_TestValidator) Valid(_ *jwt.Token, _ *gorm.DB) bool {
+	return v.valid
+}
+
+func TestRefresh(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.Auth.Token.Key = "TestKey"
+	Settings.Auth.Token.Lifespan = 60
+	defer func() {
+		Settings.Auth.Token.Lifespan = 0
+	}()
+	validator := &_TestValidator{valid: true}
+	Validators = []Validator{validator}
+	defer func() {
+		Validators = nil
+	}()
+	p := Builtin{}
+	user := "myUser"
+	scopes := []string{"things:get"}
+	//
+	// Access token expires.
+	signed, err := p.NewToken(user, scopes, jwt.MapClaims{"task": 1})
+	g.Expect(err).To(gomega.BeNil())
+	jwToken, err := p.Authenticate(&Request{Token: signed})
+	g.Expect(err).To(gomega.BeNil())
+	_, found := jwToken.Claims.(jwt.MapClaims)["exp"]
+	g.Expect(found).To(gomega.BeTrue())
+	//
+	// Other tokens do not expire.
+	signed, err = p.NewToken(user, scopes, jwt.MapClaims{})
+	g.Expect(err).To(gomega.BeNil())
+	jwToken, err = p.Authenticate(&Request{Token: signed})
+	g.Expect(err).To(gomega.BeNil())
+	_, found = jwToken.Claims.(jwt.MapClaims)["exp"]
+	g.Expect(found).To(gomega.BeFalse())
+	//
+	// Refresh token not accepted as access token.
+	refresh, err := p.NewToken(user, scopes, jwt.MapClaims{"task": 1, "refresh": true})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = p.Authenticate(&Request{Token: refresh})
+	g.Expect(errors.Is(err, &NotAuthenticated{})).To(gomega.BeTrue())
+	//
+	// Refresh.
+	token, err := p.Refresh(refresh)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(token.Refresh).To(gomega.Equal(refresh))
+	g.Expect(token.Expiry).To(gomega.Equal(60))
+	jwToken, err = p.Authenticate(&Request{Token: token.Access})
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(p.User(jwToken)).To(gomega.Equal(user))
+	g.Expect(jwToken.Claims.(jwt.MapClaims)["task"]).To(gomega.Equal(float64(1)))
+	//
+	// Access token cannot refresh.
+	signed, err = p.NewToken(user, scopes, jwt.MapClaims{"task": 1})
+	g.Expect(err).To(gomega.BeNil())
+	_, err = p.Refresh(signed)
+	g.Expect(errors.Is(err, &NotAuthenticated{})).To(gomega.BeTrue())
+	//
+	// Revoked.
+	validator.valid = false
+	_, err = p.Refresh(refresh)
+	g.Expect(errors.Is(err, &NotValid{})).To(gomega.BeTrue())
+}
+
 func TestScope(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 	scope := BaseScope{}
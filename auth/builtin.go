@@ -5,6 +5,7 @@ import (
 	liberr "github.com/jortel/go-utils/error"
 	"gorm.io/gorm"
 	"strings"
+	"time"
 )
 
 //
@@ -66,62 +67,24 @@ func (r *NoAuth) Refresh(refresh string) (token Token, err error) {
 //
 // Builtin auth provider.
 type Builtin struct {
+	// DB used to validate refreshed tokens.
+	DB *gorm.DB
 }
 
 //
 // Authenticate the token
 func (r *Builtin) Authenticate(request *Request) (jwToken *jwt.Token, err error) {
 	token := request.Token
-	jwToken, err = jwt.Parse(
-		request.Token,
-		func(jwToken *jwt.Token) (secret interface{}, err error) {
-			_, cast := jwToken.Method.(*jwt.SigningMethodHMAC)
-			if !cast {
-				err = liberr.Wrap(&NotAuthenticated{Token: token})
-				return
-			}
-			secret = []byte(Settings.Auth.Token.Key)
-			return
-		})
+	jwToken, err = r.parse(token)
 	if err != nil {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
-		return
-	}
-	if !jwToken.Valid {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
 		return
 	}
-	claims, cast := jwToken.Claims.(jwt.MapClaims)
-	if !cast {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
-		return
-	}
-	v, found := claims["user"]
-	if !found {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
-		return
-	}
-	_, cast = v.(string)
-	if !cast {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
-		return
-	}
-	v, found = claims["scope"]
-	if !found {
-		err = liberr.Wrap(&NotAuthenticated{Token: token})
-		return
-	}
-	_, cast = v.(string)
-	if !cast {
+	claims := jwToken.Claims.(jwt.MapClaims)
+	if _, found := claims["refresh"]; found {
 		err = liberr.Wrap(&NotAuthenticated{Token: token})
 		return
 	}
-	for _, v := range Validators {
-		if !v.Valid(jwToken, request.DB) {
-			err = liberr.Wrap(&NotValid{Token: token})
-			return
-		}
-	}
+	err = r.validate(jwToken, request.DB)
 	return
 }
 
@@ -155,12 +118,49 @@ func (r *Builtin) Login(user, password string) (token Token, err error) {
 
 //
 // Refresh token.
+// The refresh token (created with the `refresh` claim) is
+// validated and a new token is created with the same user,
+// scopes and claims.
 func (r *Builtin) Refresh(refresh string) (token Token, err error) {
+	jwToken, err := r.parse(refresh)
+	if err != nil {
+		return
+	}
+	claims := jwToken.Claims.(jwt.MapClaims)
+	if _, found := claims["refresh"]; !found {
+		err = liberr.Wrap(&NotAuthenticated{Token: refresh})
+		return
+	}
+	err = r.validate(jwToken, r.DB)
+	if err != nil {
+		return
+	}
+	newClaims := jwt.MapClaims{}
+	for k, v := range claims {
+		switch k {
+		case "user", "scope", "refresh", "exp":
+		default:
+			newClaims[k] = v
+		}
+	}
+	token.Access, err = r.NewToken(
+		r.User(jwToken),
+		strings.Fields(claims["scope"].(string)),
+		newClaims)
+	if err != nil {
+		return
+	}
+	token.Refresh = refresh
+	if _, task := newClaims["task"]; task {
+		token.Expiry = Settings.Auth.Token.Lifespan
+	}
 	return
 }
 
 //
 // NewToken creates a new signed token.
+// Task tokens expire after the configured lifespan unless
+// they are refresh tokens.
 func (r *Builtin) NewToken(user string, scopes []string, claims jwt.MapClaims) (signed string, err error) {
 	token := jwt.New(jwt.SigningMethodHS512)
 	jwtClaims := token.Claims.(jwt.MapClaims)
@@ -169,6 +169,76 @@ func (r *Builtin) NewToken(user string, scopes []string, claims jwt.MapClaims) (
 	}
 	jwtClaims["user"] = user
 	jwtClaims["scope"] = strings.Join(scopes, " ")
+	_, task := jwtClaims["task"]
+	_, refresh := jwtClaims["refresh"]
+	lifespan := Settings.Auth.Token.Lifespan
+	if lifespan > 0 && task && !refresh {
+		expiry := time.Now().Add(time.Duration(lifespan) * time.Second)
+		jwtClaims["exp"] = expiry.Unix()
+	}
 	signed, err = token.SignedString([]byte(Settings.Auth.Token.Key))
 	return
 }
+
+//
+// parse and verify the signed token.
+// The token must include the user and scope claims.
+func (r *Builtin) parse(token string) (jwToken *jwt.Token, err error) {
+	jwToken, err = jwt.Parse(
+		token,
+		func(jwToken *jwt.Token) (secret interface{}, err error) {
+			_, cast := jwToken.Method.(*jwt.SigningMethodHMAC)
+			if !cast {
+				err = liberr.Wrap(&NotAuthenticated{Token: token})
+				return
+			}
+			secret = []byte(Settings.Auth.Token.Key)
+			return
+		})
+	if err != nil {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	if !jwToken.Valid {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	claims, cast := jwToken.Claims.(jwt.MapClaims)
+	if !cast {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	v, found := claims["user"]
+	if !found {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	_, cast = v.(string)
+	if !cast {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	v, found = claims["scope"]
+	if !found {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	_, cast = v.(string)
+	if !cast {
+		err = liberr.Wrap(&NotAuthenticated{Token: token})
+		return
+	}
+	return
+}
+
+//
+// validate the token using the validators.
+func (r *Builtin) validate(jwToken *jwt.Token, db *gorm.DB) (err error) {
+	for _, v := range Validators {
+		if !v.Valid(jwToken, db) {
+			err = liberr.Wrap(&NotValid{Token: jwToken.Raw})
+			return
+		}
+	}
+	return
+}
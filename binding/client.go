@@ -79,6 +79,8 @@ type Client struct {
 	baseURL string
 	// addon API token
 	token string
+	// addon API refresh token.
+	refresh string
 	// transport
 	transport http.RoundTripper
 	// Retry limit.
@@ -93,6 +95,13 @@ func (r *Client) SetToken(token string) {
 	r.token = token
 }
 
+//
+// SetRefreshToken sets the token used to refresh the
+// hub token when expired.
+func (r *Client) SetRefreshToken(refresh string) {
+	r.refresh = refresh
+}
+
 //
 // Reset the client.
 func (r *Client) Reset() {
@@ -716,6 +725,7 @@ func (r *Client) send(rb func() (*http.Request, error)) (response *http.Response
 	if err != nil {
 		return
 	}
+	refreshed := false
 	for i := 0; ; i++ {
 		request, err = rb()
 		if err != nil {
@@ -747,12 +757,67 @@ func (r *Client) send(rb func() (*http.Request, error)) (response *http.Response
 					response.StatusCode,
 					request.Method,
 					request.URL.Path))
+			if response.StatusCode == http.StatusUnauthorized &&
+				r.refresh != "" &&
+				!refreshed {
+				_ = response.Body.Close()
+				err = r.refreshToken()
+				if err != nil {
+					return
+				}
+				refreshed = true
+				continue
+			}
 			break
 		}
 	}
 	return
 }
 
+//
+// refreshToken obtains a new token using the refresh token.
+func (r *Client) refreshToken() (err error) {
+	login := api.Login{Refresh: r.refresh}
+	b, _ := json.Marshal(login)
+	request := &http.Request{
+		Header: http.Header{},
+		Method: http.MethodPost,
+		Body:   io.NopCloser(bytes.NewReader(b)),
+		URL:    r.join(api.AuthRefreshRoot),
+	}
+	request.Header.Set(api.ContentType, binding.MIMEJSON)
+	client := http.Client{Transport: r.transport}
+	response, err := client.Do(request)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	status := response.StatusCode
+	if status != http.StatusCreated {
+		err = liberr.New(
+			"Token refresh failed.",
+			"status",
+			status)
+		return
+	}
+	b, err = io.ReadAll(response.Body)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	err = json.Unmarshal(b, &login)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	r.token = login.Token
+	Log.Info("Token refreshed.")
+	return
+}
+
 //
 // buildTransport builds transport.
 func (r *Client) buildTransport() (err error) {
@@ -15,12 +15,15 @@ import (
 	"github.com/konveyor/tackle2-hub/metrics"
 	"github.com/konveyor/tackle2-hub/migration"
 	"github.com/konveyor/tackle2-hub/reaper"
+	"github.com/konveyor/tackle2-hub/scheduler"
 	"github.com/konveyor/tackle2-hub/settings"
 	"github.com/konveyor/tackle2-hub/task"
 	"github.com/konveyor/tackle2-hub/tracker"
 	"gorm.io/gorm"
+	core "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"syscall"
@@ -68,6 +71,14 @@ func addonManager(db *gorm.DB) (mgr manager.Manager, err error) {
 		manager.Options{
 			MetricsBindAddress: "0",
 			Namespace:          Settings.Hub.Namespace,
+			NewCache: cache.BuilderWithOptions(
+				cache.Options{
+					SelectorsByObject: cache.SelectorsByObject{
+						&core.Pod{}: {
+							Label: task.PodSelector(),
+						},
+					},
+				}),
 		})
 	if err != nil {
 		err = liberr.Wrap(err)
@@ -98,13 +109,28 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	//
+	// k8s scheme.
 	if !Settings.Disconnected {
-		//
-		// k8s scheme.
 		err = buildScheme()
 		if err != nil {
 			return
 		}
+	}
+	//
+	// k8s client.
+	client, err := k8s.NewClient()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	//
+	// Task
+	taskManager := task.Manager{
+		Client: client,
+		DB:     db,
+	}
+	if !Settings.Disconnected {
 		//
 		// Add controller.
 		addonManager, aErr := addonManager(db)
@@ -112,6 +138,12 @@ func main() {
 			err = aErr
 			return
 		}
+		//
+		// Watch task pods.
+		err = taskManager.Watch(addonManager)
+		if err != nil {
+			return
+		}
 		go func() {
 			err = addonManager.Start(context.Background())
 			if err != nil {
@@ -121,13 +153,6 @@ func main() {
 		}()
 	}
 	//
-	// k8s client.
-	client, err := k8s.NewClient()
-	if err != nil {
-		err = liberr.Wrap(err)
-		return
-	}
-	//
 	// Auth
 	if settings.Settings.Auth.Required {
 		r := auth.NewReconciler(
@@ -143,7 +168,9 @@ func main() {
 		if err != nil {
 			return
 		}
-		auth.Hub = &auth.Builtin{}
+		auth.Hub = &auth.Builtin{
+			DB: db,
+		}
 		auth.Remote = auth.NewKeycloak(
 			settings.Settings.Auth.Keycloak.Host,
 			settings.Settings.Auth.Keycloak.Realm,
@@ -151,11 +178,8 @@ func main() {
 	}
 	//
 	// Task
-	taskManager := task.Manager{
-		Client: client,
-		DB:     db,
-	}
 	taskManager.Run(context.Background())
+	task.Stream.Run(context.Background(), db)
 	//
 	// Reaper
 	reaperManager := reaper.Manager{
@@ -164,6 +188,13 @@ func main() {
 	}
 	reaperManager.Run(context.Background())
 	//
+	// Scheduled tasks.
+	scheduleManager := scheduler.Manager{
+		Client: client,
+		DB:     db,
+	}
+	scheduleManager.Run(context.Background())
+	//
 	// Application import.
 	importManager := importer.Manager{
 		DB: db,
@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	core "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 //
@@ -32,6 +33,109 @@ type AddonSpec struct {
 	ImagePullPolicy core.PullPolicy `json:"imagePullPolicy,omitempty"`
 	// Resource requirements.
 	Resources core.ResourceRequirements `json:"resources,omitempty"`
+	// Limit the number of (running+pending) tasks.
+	// Overrides the hub default. 0=default.
+	// +kubebuilder:validation:Minimum=0
+	Limit int `json:"limit,omitempty"`
+	// Deadline (seconds) the default active deadline for tasks.
+	// Tasks running longer are terminated. 0=none.
+	// +kubebuilder:validation:Minimum=0
+	Deadline int `json:"deadline,omitempty"`
+	// Retry the default retry policy for tasks.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Kind the kind (capability) of task provided by the addon.
+	// Tasks may name a kind instead of an addon.
+	Kind string `json:"kind,omitempty"`
+	// Selector selects the applications supported by the addon.
+	// Used to select the addon for tasks naming a kind.
+	Selector *AddonSelector `json:"selector,omitempty"`
+	// Schema (JSON Schema) used to validate task data.
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Schema *runtime.RawExtension `json:"schema,omitempty"`
+	// Scopes the (hub API) scopes granted to the task token.
+	// Overrides the default addon role.
+	Scopes []string `json:"scopes,omitempty"`
+	// NodeSelector the task pod node selector.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations the task pod tolerations.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Tolerations []core.Toleration `json:"tolerations,omitempty"`
+	// Affinity the task pod affinity.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Affinity *core.Affinity `json:"affinity,omitempty"`
+	// Env additional container environment variables.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Env []core.EnvVar `json:"env,omitempty"`
+	// EnvFrom additional container environment sources.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	EnvFrom []core.EnvFromSource `json:"envFrom,omitempty"`
+	// Volumes additional task pod volumes.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Volumes []core.Volume `json:"volumes,omitempty"`
+	// VolumeMounts additional container volume mounts.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	VolumeMounts []core.VolumeMount `json:"volumeMounts,omitempty"`
+	// PodSecurityContext the task pod security context.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	PodSecurityContext *core.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// SecurityContext the container security context.
+	// Overrides the default (run as root).
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	SecurityContext *core.SecurityContext `json:"securityContext,omitempty"`
+}
+
+//
+// AddonSelector selects applications by tags and facts.
+// All of the tags and facts must match.
+type AddonSelector struct {
+	// Tags matched as: category=name. (case-insensitive).
+	Tags []string `json:"tags,omitempty"`
+	// Facts matched as: key=value. (case-insensitive).
+	Facts []string `json:"facts,omitempty"`
+}
+
+//
+// RetryPolicy defines how failed tasks are retried.
+type RetryPolicy struct {
+	// Limit the number of retries. Not specified=hub default.
+	// +kubebuilder:validation:Minimum=0
+	Limit *int `json:"limit,omitempty"`
+	// Backoff (seconds) the delay before the first retry.
+	// The delay is doubled for each subsequent retry.
+	// +kubebuilder:validation:Minimum=0
+	Backoff int `json:"backoff,omitempty"`
+	// MaxBackoff (seconds) the maximum delay. 0=unbounded.
+	// +kubebuilder:validation:Minimum=0
+	MaxBackoff int `json:"maxBackoff,omitempty"`
+	// Jitter (percent) the delay is randomly adjusted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Jitter int `json:"jitter,omitempty"`
+	// Rules determine whether a failure is retryable.
+	// The first matched rule is applied.
+	Rules []RetryRule `json:"rules,omitempty"`
+}
+
+//
+// RetryRule determines whether a failure is retryable.
+type RetryRule struct {
+	// Reason matched with the (container) termination reason.
+	// Empty matches all.
+	Reason string `json:"reason,omitempty"`
+	// ExitCodes matched with the (container) exit code.
+	// Empty matches all.
+	ExitCodes []int32 `json:"exitCodes,omitempty"`
+	// Retry the failure is retryable.
+	Retry bool `json:"retry"`
 }
 
 //
@@ -6,6 +6,8 @@ import (
 	"github.com/konveyor/tackle2-hub/settings"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -32,6 +34,26 @@ func NewClient() (newClient client.Client, err error) {
 	return
 }
 
+//
+// NewClientSet builds new k8s client set.
+// Needed for (sub)resources such as pod logs.
+func NewClientSet() (clientSet kubernetes.Interface, err error) {
+	if Settings.Disconnected {
+		clientSet = fake.NewSimpleClientset()
+		return
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	clientSet, err = kubernetes.NewForConfig(cfg)
+	if err != nil {
+		err = liberr.Wrap(err)
+	}
+	return
+}
+
 type FakeClient struct {
 }
 
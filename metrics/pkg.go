@@ -14,6 +14,10 @@ var (
 		Name: "konveyor_tasks_initiated_total",
 		Help: "The total number of initiated tasks",
 	})
+	TasksTimedOut = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "konveyor_tasks_timedout_total",
+		Help: "The total number of tasks terminated for exceeding the deadline",
+	})
 	Applications = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "konveyor_applications_inventoried",
 		Help: "The current number of applications in inventory",
@@ -22,4 +26,4 @@ var (
 		Name: "konveyor_issues_exported_total",
 		Help: "The total number of issues exported to external trackers",
 	})
-)
\ No newline at end of file
+)
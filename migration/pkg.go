@@ -7,6 +7,7 @@ import (
 	v4 "github.com/konveyor/tackle2-hub/migration/v4"
 	v5 "github.com/konveyor/tackle2-hub/migration/v5"
 	v6 "github.com/konveyor/tackle2-hub/migration/v6"
+	v7 "github.com/konveyor/tackle2-hub/migration/v7"
 	"github.com/konveyor/tackle2-hub/settings"
 	"gorm.io/gorm"
 )
@@ -45,5 +46,6 @@ func All() []Migration {
 		v4.Migration{},
 		v5.Migration{},
 		v6.Migration{},
+		v7.Migration{},
 	}
 }
@@ -0,0 +1,86 @@
+package model
+
+import "github.com/konveyor/tackle2-hub/migration/v6/model"
+
+//
+// JSON field (data) type.
+type JSON = []byte
+
+//
+// Unchanged models imported from previous migration.
+type Model = model.Model
+type Application = model.Application
+type TechDependency = model.TechDependency
+type Incident = model.Incident
+type Analysis = model.Analysis
+type Issue = model.Issue
+type Bucket = model.Bucket
+type BucketOwner = model.BucketOwner
+type BusinessService = model.BusinessService
+type Dependency = model.Dependency
+type File = model.File
+type Fact = model.Fact
+type Identity = model.Identity
+type Import = model.Import
+type ImportSummary = model.ImportSummary
+type ImportTag = model.ImportTag
+type JobFunction = model.JobFunction
+type MigrationWave = model.MigrationWave
+type Proxy = model.Proxy
+type Review = model.Review
+type Setting = model.Setting
+type RuleSet = model.RuleSet
+type Rule = model.Rule
+type Stakeholder = model.Stakeholder
+type StakeholderGroup = model.StakeholderGroup
+type Tag = model.Tag
+type TagCategory = model.TagCategory
+type Ticket = model.Ticket
+type Tracker = model.Tracker
+type ApplicationTag = model.ApplicationTag
+
+//
+// Errors
+type DependencyCyclicError = model.DependencyCyclicError
+
+//
+// All builds all models.
+// Models are enumerated such that each are listed after
+// all the other models on which they may depend.
+func All() []interface{} {
+	return []interface{}{
+		TechDependency{},
+		Incident{},
+		Issue{},
+		Analysis{},
+		ImportSummary{},
+		Import{},
+		ImportTag{},
+		JobFunction{},
+		TagCategory{},
+		Tag{},
+		StakeholderGroup{},
+		Stakeholder{},
+		BusinessService{},
+		Bucket{},
+		Application{},
+		ApplicationTag{},
+		Dependency{},
+		Review{},
+		Identity{},
+		Task{},
+		TaskGroup{},
+		TaskReport{},
+		TaskEvent{},
+		Schedule{},
+		Waiver{},
+		Proxy{},
+		Tracker{},
+		Ticket{},
+		File{},
+		Fact{},
+		RuleSet{},
+		Rule{},
+		MigrationWave{},
+	}
+}
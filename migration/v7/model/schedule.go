@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+//
+// Schedule creates tasks (or task groups) on a cron schedule.
+// The Task and TaskGroup fields contain the template.
+type Schedule struct {
+	Model
+	Name      string `gorm:"uniqueIndex"`
+	Cron      string
+	Paused    bool
+	Task      JSON
+	TaskGroup JSON
+	LastRun   *time.Time
+	NextRun   *time.Time
+	Errors    JSON
+}
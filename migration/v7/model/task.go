@@ -0,0 +1,311 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Task struct {
+	Model
+	BucketOwner
+	Name          string `gorm:"index"`
+	Addon         string `gorm:"index"`
+	Kind          string `gorm:"index"`
+	Stage         int
+	Locator       string `gorm:"index"`
+	Priority      int
+	Image         string
+	Variant       string
+	Policy        string
+	TTL           JSON
+	Data          JSON
+	Started       *time.Time
+	Terminated    *time.Time
+	State         string `gorm:"index"`
+	Errors        JSON
+	Postponed     JSON
+	Pod           string `gorm:"index"`
+	TokenID       string
+	Retries       int
+	RetryPolicy   JSON
+	NextRetry     *time.Time
+	Deadline      int
+	Canceled      bool
+	Report        *TaskReport `gorm:"constraint:OnDelete:CASCADE"`
+	ApplicationID *uint
+	Application   *Application
+	TaskGroupID   *uint `gorm:"<-:create"`
+	TaskGroup     *TaskGroup
+	DependsOn     []Task `gorm:"many2many:TaskDependencies;constraint:OnDelete:CASCADE"`
+	OriginID      *uint  `gorm:"<-:create;index"`
+	Origin        *Task  `gorm:"constraint:OnDelete:SET NULL"`
+	// prior (persisted) state.
+	prior struct {
+		found bool
+		state string
+		pod   string
+	}
+	// reason for the state transition.
+	reason string
+}
+
+func (m *Task) Reset() {
+	m.Started = nil
+	m.Terminated = nil
+	m.Report = nil
+	m.Errors = nil
+	m.Postponed = nil
+	m.NextRetry = nil
+	m.TokenID = ""
+}
+
+func (m *Task) BeforeCreate(db *gorm.DB) (err error) {
+	err = m.BucketOwner.BeforeCreate(db)
+	m.Reset()
+	return
+}
+
+//
+// AfterCreate hook records the initial state transition.
+func (m *Task) AfterCreate(db *gorm.DB) (err error) {
+	err = m.transitioned(db, "")
+	m.persisted()
+	return
+}
+
+//
+// AfterFind hook records the persisted state.
+func (m *Task) AfterFind(db *gorm.DB) (err error) {
+	m.persisted()
+	return
+}
+
+//
+// BeforeUpdate hook to avoid cyclic dependencies.
+// The persisted state is fetched to detect transitions only
+// when not already known and the state is being updated.
+func (m *Task) BeforeUpdate(db *gorm.DB) (err error) {
+	err = m.cyclic(db)
+	if err != nil {
+		return
+	}
+	if m.prior.found || !m.updated(db, "State") {
+		return
+	}
+	current := &Task{}
+	db = db.Session(&gorm.Session{NewDB: true})
+	err = db.Select("State", "Pod").Take(current, m.ID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		return
+	}
+	m.prior = current.prior
+	return
+}
+
+//
+// AfterUpdate hook records state transitions.
+func (m *Task) AfterUpdate(db *gorm.DB) (err error) {
+	if db.Statement.RowsAffected == 0 {
+		return
+	}
+	err = m.transitioned(db, m.prior.state)
+	return
+}
+
+//
+// Because sets the reason for the next state transition.
+func (m *Task) Because(reason string, x ...interface{}) {
+	m.reason = fmt.Sprintf(reason, x...)
+}
+
+//
+// transitioned records the state transition (event) as needed.
+func (m *Task) transitioned(db *gorm.DB, previous string) (err error) {
+	if m.ID == 0 || m.State == "" || m.State == previous {
+		return
+	}
+	reason := m.reason
+	if reason == "" {
+		switch m.State {
+		case "Postponed":
+			p := TaskPostponed{}
+			_ = json.Unmarshal(m.Postponed, &p)
+			reason = p.Rule
+		case "Failed", "TimedOut":
+			var list []TaskError
+			_ = json.Unmarshal(m.Errors, &list)
+			if len(list) > 0 {
+				reason = list[len(list)-1].Description
+			}
+		}
+	}
+	pod := m.Pod
+	if pod == "" {
+		pod = m.prior.pod
+	}
+	event := &TaskEvent{
+		TaskID:   m.ID,
+		Previous: previous,
+		State:    m.State,
+		Reason:   reason,
+		Pod:      pod,
+		Retry:    m.Retries,
+	}
+	db = db.Session(&gorm.Session{NewDB: true})
+	err = db.Create(event).Error
+	if err != nil {
+		return
+	}
+	m.persisted()
+	m.reason = ""
+	return
+}
+
+//
+// persisted records the persisted state.
+func (m *Task) persisted() {
+	m.prior.found = true
+	m.prior.state = m.State
+	m.prior.pod = m.Pod
+}
+
+//
+// updated returns true when the field is included in the update.
+func (m *Task) updated(db *gorm.DB, field string) (found bool) {
+	if fields, cast := db.Statement.Dest.(map[string]interface{}); cast {
+		_, found = fields[field]
+		return
+	}
+	for _, name := range db.Statement.Omits {
+		if name == field {
+			return
+		}
+	}
+	if len(db.Statement.Selects) == 0 {
+		found = true
+		return
+	}
+	for _, name := range db.Statement.Selects {
+		if name == field || name == "*" {
+			found = true
+			return
+		}
+	}
+	return
+}
+
+//
+// cyclic returns an error when a dependency is cyclic.
+func (m *Task) cyclic(db *gorm.DB) (err error) {
+	seen := make(map[uint]bool)
+	var nextDeps []Task
+	var nextTaskIDs []uint
+	for _, dep := range m.DependsOn {
+		if dep.ID == m.ID {
+			err = DependencyCyclicError{}
+			return
+		}
+		nextTaskIDs = append(nextTaskIDs, dep.ID)
+	}
+	for len(nextTaskIDs) != 0 {
+		result := db.Preload("DependsOn").Where("ID IN ?", nextTaskIDs).Find(&nextDeps)
+		if result.Error != nil {
+			err = result.Error
+			return
+		}
+		nextTaskIDs = nextTaskIDs[:0]
+		for _, nextDep := range nextDeps {
+			for _, dep := range nextDep.DependsOn {
+				if seen[dep.ID] {
+					continue
+				}
+				if dep.ID == m.ID {
+					err = DependencyCyclicError{}
+					return
+				}
+				seen[dep.ID] = true
+				nextTaskIDs = append(nextTaskIDs, dep.ID)
+			}
+		}
+	}
+
+	return
+}
+
+//
+// Error appends an error.
+func (m *Task) Error(severity, description string, x ...interface{}) {
+	var list []TaskError
+	description = fmt.Sprintf(description, x...)
+	te := TaskError{Severity: severity, Description: description}
+	_ = json.Unmarshal(m.Errors, &list)
+	list = append(list, te)
+	m.Errors, _ = json.Marshal(list)
+}
+
+//
+// Postpone records the rule (and task) by which the task is postponed.
+func (m *Task) Postpone(rule string, by uint) {
+	p := TaskPostponed{Rule: rule, TaskID: by}
+	m.Postponed, _ = json.Marshal(p)
+}
+
+//
+// Map alias.
+type Map = map[string]interface{}
+
+//
+// TTL time-to-live.
+type TTL struct {
+	Created   int `json:"created,omitempty"`
+	Pending   int `json:"pending,omitempty"`
+	Postponed int `json:"postponed,omitempty"`
+	Running   int `json:"running,omitempty"`
+	Succeeded int `json:"succeeded,omitempty"`
+	Failed    int `json:"failed,omitempty"`
+}
+
+//
+// TaskError used in Task.Errors.
+type TaskError struct {
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+//
+// TaskPostponed used in Task.Postponed.
+type TaskPostponed struct {
+	Rule   string `json:"rule"`
+	TaskID uint   `json:"task,omitempty"`
+}
+
+//
+// TaskEvent task state transition.
+type TaskEvent struct {
+	Model
+	Previous string
+	State    string
+	Reason   string
+	Pod      string
+	Retry    int
+	TaskID   uint  `gorm:"<-:create;index"`
+	Task     *Task `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type TaskReport struct {
+	Model
+	Status    string
+	Errors    JSON
+	Total     int
+	Completed int
+	Activity  JSON `gorm:"type:json"`
+	Result    JSON `gorm:"type:json"`
+	TaskID    uint `gorm:"<-:create;uniqueIndex"`
+	Task      *Task
+}
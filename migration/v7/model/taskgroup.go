@@ -0,0 +1,154 @@
+package model
+
+import (
+	"encoding/json"
+	liberr "github.com/jortel/go-utils/error"
+	"strings"
+)
+
+type TaskGroup struct {
+	Model
+	BucketOwner
+	Name   string
+	Addon  string
+	Kind   string
+	Data   JSON
+	Stages JSON
+	Tasks  []Task `gorm:"constraint:OnDelete:CASCADE"`
+	List   JSON
+	State  string
+}
+
+//
+// TaskStage a pipeline stage.
+type TaskStage struct {
+	Name  string `json:"name"`
+	Addon string `json:"addon,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Data  Map    `json:"data,omitempty"`
+}
+
+//
+// Propagate group data into the task.
+func (m *TaskGroup) Propagate() (err error) {
+	err = m.staged()
+	if err != nil {
+		return
+	}
+	for i := range m.Tasks {
+		task := &m.Tasks[i]
+		task.State = m.State
+		task.SetBucket(m.BucketID)
+		if task.Addon == "" {
+			task.Addon = m.Addon
+		}
+		if task.Kind == "" {
+			task.Kind = m.Kind
+		}
+		if m.Data == nil {
+			continue
+		}
+		a := Map{}
+		err = json.Unmarshal(m.Data, &a)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"id",
+				m.ID)
+			return
+		}
+		b := Map{}
+		err = json.Unmarshal(task.Data, &b)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"id",
+				m.ID)
+			return
+		}
+		task.Data, _ = json.Marshal(m.merge(a, b))
+	}
+
+	return
+}
+
+//
+// staged expands the tasks into a task for each pipeline stage.
+// Stages are numbered (1-n) and the stage data is merged into
+// the task data. The task data is the authority.
+func (m *TaskGroup) staged() (err error) {
+	var stages []TaskStage
+	if m.Stages != nil {
+		err = json.Unmarshal(m.Stages, &stages)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"id",
+				m.ID)
+			return
+		}
+	}
+	if len(stages) == 0 {
+		return
+	}
+	var tasks []Task
+	for _, member := range m.Tasks {
+		b := Map{}
+		err = json.Unmarshal(member.Data, &b)
+		if err != nil {
+			err = liberr.Wrap(
+				err,
+				"id",
+				m.ID)
+			return
+		}
+		for i, stage := range stages {
+			task := member
+			task.Stage = i + 1
+			task.Name = strings.Join([]string{member.Name, stage.Name}, ".")
+			task.Addon = stage.Addon
+			task.Kind = stage.Kind
+			task.Data, _ = json.Marshal(m.merge(stage.Data, b))
+			tasks = append(tasks, task)
+		}
+	}
+	m.Tasks = tasks
+	return
+}
+
+//
+// merge maps B into A.
+// The B map is the authority.
+func (m *TaskGroup) merge(a, b Map) (out Map) {
+	if a == nil {
+		a = Map{}
+	}
+	if b == nil {
+		b = Map{}
+	}
+	out = Map{}
+	//
+	// Merge-in elements found in B and in A.
+	for k, v := range a {
+		out[k] = v
+		if bv, found := b[k]; found {
+			out[k] = bv
+			if av, cast := v.(Map); cast {
+				if bv, cast := bv.(Map); cast {
+					out[k] = m.merge(av, bv)
+				} else {
+					out[k] = bv
+				}
+			}
+		}
+	}
+	//
+	// Add elements found only in B.
+	for k, v := range b {
+		if _, found := a[k]; !found {
+			out[k] = v
+		}
+	}
+
+	return
+}
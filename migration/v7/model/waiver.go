@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+//
+// Waiver suppresses (waives) analysis issues.
+// Matched by ruleset and rule and optionally by application,
+// incident file (glob) and incident fingerprint.
+// The author is the CreateUser.
+type Waiver struct {
+	Model
+	RuleSet       string       `gorm:"index;not null"`
+	Rule          string       `gorm:"index;not null"`
+	ApplicationID *uint        `gorm:"index"`
+	Application   *Application `gorm:"constraint:OnDelete:CASCADE"`
+	File          string
+	Fingerprint   string
+	Justification string `gorm:"not null"`
+	Expiration    *time.Time
+}
@@ -1,7 +1,7 @@
 package model
 
 import (
-	"github.com/konveyor/tackle2-hub/migration/v6/model"
+	"github.com/konveyor/tackle2-hub/migration/v7/model"
 	"gorm.io/datatypes"
 )
 
@@ -33,6 +33,7 @@ type Proxy = model.Proxy
 type Review = model.Review
 type Setting = model.Setting
 type RuleSet = model.RuleSet
+type Schedule = model.Schedule
 type Rule = model.Rule
 type Stakeholder = model.Stakeholder
 type StakeholderGroup = model.StakeholderGroup
@@ -41,11 +42,16 @@ type TagCategory = model.TagCategory
 type Task = model.Task
 type TaskGroup = model.TaskGroup
 type TaskReport = model.TaskReport
+type TaskEvent = model.TaskEvent
 type Ticket = model.Ticket
 type Tracker = model.Tracker
+type Waiver = model.Waiver
 
 //
 type TTL = model.TTL
+type TaskError = model.TaskError
+type TaskPostponed = model.TaskPostponed
+type TaskStage = model.TaskStage
 
 //
 // Join tables
@@ -39,7 +39,7 @@ type TaskReaper struct {
 //     settings.Task.Reaper.Succeeded.
 //   - Bucket is released after the defined period.
 //   - Pod is deleted after the defined period.
-//   Failed
+//   Failed|TimedOut
 //   - Deleted after TTL > terminated timestamp or
 //     settings.Task.Reaper.Failed.
 //   - Bucket is released after the defined period.
@@ -54,6 +54,7 @@ func (r *TaskReaper) Run() {
 			task.Created,
 			task.Succeeded,
 			task.Failed,
+			task.TimedOut,
 		})
 	Log.Error(result.Error, "")
 	if result.Error != nil {
@@ -113,7 +114,8 @@ func (r *TaskReaper) Run() {
 					r.release(m)
 				}
 			}
-		case task.Failed:
+		case task.Failed,
+			task.TimedOut:
 			mark := *m.Terminated
 			if ttl.Succeeded > 0 {
 				d := time.Duration(ttl.Failed) * Unit
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/jortel/go-utils/logr"
+	"github.com/konveyor/tackle2-hub/api"
+	"github.com/konveyor/tackle2-hub/model"
+	"github.com/konveyor/tackle2-hub/settings"
+	"github.com/konveyor/tackle2-hub/task"
+	"gorm.io/gorm"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const (
+	Unit = time.Second
+)
+
+var (
+	Settings = &settings.Settings
+	Log      = logr.WithName("scheduler")
+)
+
+//
+// Manager provides scheduled task management.
+type Manager struct {
+	// DB
+	DB *gorm.DB
+	// k8s client.
+	Client k8s.Client
+}
+
+//
+// Run the manager.
+func (m *Manager) Run(ctx context.Context) {
+	go func() {
+		Log.Info("Started.")
+		defer Log.Info("Died.")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				m.schedule()
+				m.pause()
+			}
+		}
+	}()
+}
+
+//
+// schedule creates tasks (and groups) for schedules
+// which are due.
+func (m *Manager) schedule() {
+	var list []model.Schedule
+	db := m.DB.Where("Paused", false)
+	err := db.Find(&list).Error
+	if err != nil {
+		Log.Error(err, "")
+		return
+	}
+	now := time.Now()
+	for i := range list {
+		schedule := &list[i]
+		if schedule.NextRun == nil {
+			next, err := api.NextRun(schedule.Cron, now)
+			if err != nil {
+				Log.Error(err, "", "schedule", schedule.ID)
+				continue
+			}
+			schedule.NextRun = &next
+			err = m.DB.Model(schedule).Update("NextRun", next).Error
+			if err != nil {
+				Log.Error(err, "")
+			}
+			continue
+		}
+		if schedule.NextRun.After(now) {
+			continue
+		}
+		schedule.Errors = nil
+		err = m.run(schedule)
+		if err != nil {
+			Log.Error(err, "", "schedule", schedule.ID)
+			schedule.Errors, _ = json.Marshal(
+				[]model.TaskError{
+					{
+						Severity:    "Error",
+						Description: err.Error(),
+					},
+				})
+		}
+		schedule.LastRun = &now
+		next, nErr := api.NextRun(schedule.Cron, now)
+		if nErr == nil {
+			schedule.NextRun = &next
+		} else {
+			schedule.NextRun = nil
+		}
+		db := m.DB.Model(schedule)
+		db = db.Select("LastRun", "NextRun", "Errors")
+		err = db.Updates(schedule).Error
+		if err != nil {
+			Log.Error(err, "")
+		}
+	}
+}
+
+//
+// run creates (ready) task and task group defined
+// by the schedule template. The templates are (API) resources
+// and the tasks are submitted as when created using the API.
+func (m *Manager) run(schedule *model.Schedule) (err error) {
+	if schedule.TaskGroup != nil {
+		r := &api.TaskGroup{}
+		err = json.Unmarshal(schedule.TaskGroup, r)
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		group := r.Model()
+		group.ID = 0
+		group.BucketID = nil
+		group.State = task.Ready
+		group.CreateUser = schedule.CreateUser
+		for i := range group.Tasks {
+			member := &group.Tasks[i]
+			member.ID = 0
+			member.DependsOn = nil
+			member.CreateUser = schedule.CreateUser
+		}
+		err = group.Propagate()
+		if err != nil {
+			return
+		}
+		for i := range group.Tasks {
+			member := &task.Task{Task: &group.Tasks[i]}
+			err = member.Submit(m.DB, m.Client)
+			if err != nil {
+				return
+			}
+		}
+		err = m.DB.Create(group).Error
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		Log.Info(
+			"Task group created.",
+			"schedule",
+			schedule.ID,
+			"group",
+			group.ID)
+	}
+	if schedule.Task != nil {
+		r := &api.Task{}
+		err = json.Unmarshal(schedule.Task, r)
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		created := r.Model()
+		created.ID = 0
+		created.DependsOn = nil
+		created.State = task.Ready
+		created.CreateUser = schedule.CreateUser
+		submitted := &task.Task{Task: created}
+		err = submitted.Submit(m.DB, m.Client)
+		if err != nil {
+			return
+		}
+		err = m.DB.Create(created).Error
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+		Log.Info(
+			"Task created.",
+			"schedule",
+			schedule.ID,
+			"task",
+			created.ID)
+	}
+	return
+}
+
+//
+// Pause.
+func (m *Manager) pause() {
+	d := Unit * time.Duration(Settings.Frequency.Schedule)
+	time.Sleep(d)
+}
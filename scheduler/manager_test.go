@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"github.com/konveyor/tackle2-hub/api"
+	"github.com/konveyor/tackle2-hub/database"
+	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
+	"github.com/konveyor/tackle2-hub/migration"
+	"github.com/konveyor/tackle2-hub/model"
+	"github.com/konveyor/tackle2-hub/task"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	now := time.Date(2023, 1, 1, 10, 30, 0, 0, time.UTC)
+	next, err := api.NextRun("0 2 * * *", now)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(next).To(gomega.Equal(time.Date(2023, 1, 2, 2, 0, 0, 0, time.UTC)))
+	_, err = api.NextRun("invalid", now)
+	g.Expect(err).ToNot(gomega.BeNil())
+}
+
+func TestSchedule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	scheme := runtime.NewScheme()
+	err := crd.SchemeBuilder.AddToScheme(scheme)
+	g.Expect(err).To(gomega.BeNil())
+	client := fake.NewClientBuilder().WithScheme(scheme).Build()
+	m := Manager{DB: db, Client: client}
+	template, _ := json.Marshal(
+		&api.Task{
+			Name:  "test",
+			Addon: "test",
+			Data:  map[string]interface{}{"a": 1},
+		})
+	unmatched, _ := json.Marshal(
+		&api.Task{
+			Name: "test",
+			Kind: "analyzer",
+			Data: map[string]interface{}{},
+		})
+	past := time.Now().Add(-time.Minute)
+	due := &model.Schedule{
+		Name:    "due",
+		Cron:    "* * * * *",
+		Task:    template,
+		NextRun: &past,
+	}
+	paused := &model.Schedule{
+		Name:    "paused",
+		Cron:    "* * * * *",
+		Task:    template,
+		Paused:  true,
+		NextRun: &past,
+	}
+	unscheduled := &model.Schedule{
+		Name: "unscheduled",
+		Cron: "* * * * *",
+		Task: template,
+	}
+	invalid := &model.Schedule{
+		Name:    "invalid",
+		Cron:    "* * * * *",
+		Task:    unmatched,
+		NextRun: &past,
+	}
+	for _, s := range []*model.Schedule{due, paused, unscheduled, invalid} {
+		err := db.Create(s).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+
+	m.schedule()
+
+	var tasks []model.Task
+	err = db.Find(&tasks).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(tasks)).To(gomega.Equal(1))
+	g.Expect(tasks[0].State).To(gomega.Equal(task.Ready))
+	g.Expect(tasks[0].Addon).To(gomega.Equal("test"))
+	g.Expect(string(tasks[0].Data)).To(gomega.Equal(`{"a":1}`))
+	// due
+	err = db.First(due, due.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(due.LastRun).ToNot(gomega.BeNil())
+	g.Expect(due.NextRun.After(time.Now())).To(gomega.BeTrue())
+	// paused.
+	err = db.First(paused, paused.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(paused.LastRun).To(gomega.BeNil())
+	// unscheduled.
+	err = db.First(unscheduled, unscheduled.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(unscheduled.LastRun).To(gomega.BeNil())
+	g.Expect(unscheduled.NextRun).ToNot(gomega.BeNil())
+	// invalid (no addon matched).
+	err = db.First(invalid, invalid.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(invalid.LastRun).ToNot(gomega.BeNil())
+	g.Expect(string(invalid.Errors)).To(gomega.ContainSubstring("not matched"))
+}
+
+func setup(g *gomega.WithT) (db *gorm.DB) {
+	Settings.DB.Path = "/tmp/scheduler.db"
+	Settings.Bucket.Path = "/tmp/scheduler/bucket"
+	_ = os.Remove(Settings.DB.Path)
+	err := migration.Migrate(migration.All())
+	g.Expect(err).To(gomega.BeNil())
+	db, err = database.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	return
+}
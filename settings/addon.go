@@ -9,6 +9,7 @@ import (
 const (
 	EnvHubBaseURL = "HUB_BASE_URL"
 	EnvHubToken   = "TOKEN"
+	EnvHubRefresh = "TOKEN_REFRESH"
 	EnvTask       = "TASK"
 )
 
@@ -21,6 +22,8 @@ type Addon struct {
 		URL string
 		// Token for the hub API.
 		Token string
+		// Refresh token used to renew the (expired) token.
+		Refresh string
 	}
 	//
 	Task int
@@ -37,6 +40,7 @@ func (r *Addon) Load() (err error) {
 		panic(err)
 	}
 	r.Hub.Token, found = os.LookupEnv(EnvHubToken)
+	r.Hub.Refresh, found = os.LookupEnv(EnvHubRefresh)
 	if s, found := os.LookupEnv(EnvTask); found {
 		r.Task, _ = strconv.Atoi(s)
 	}
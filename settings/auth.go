@@ -2,6 +2,7 @@ package settings
 
 import (
 	"os"
+	"strconv"
 )
 
 //
@@ -17,6 +18,7 @@ const (
 	EnvKeycloakAdminRealm    = "KEYCLOAK_ADMIN_REALM"
 	EnvKeycloakReqPassUpdate = "KEYCLOAK_REQ_PASS_UPDATE"
 	EnvBuiltinTokenKey       = "ADDON_TOKEN"
+	EnvBuiltinTokenLifespan  = "ADDON_TOKEN_LIFESPAN"
 	EnvRolePath              = "ROLE_PATH"
 	EnvUserPath              = "USER_PATH"
 )
@@ -44,6 +46,8 @@ type Auth struct {
 	// Token settings for builtin provider.
 	Token struct {
 		Key string
+		// Lifespan (seconds) of task tokens. 0=unlimited.
+		Lifespan int
 	}
 }
 
@@ -86,6 +90,13 @@ func (r *Auth) Load() (err error) {
 	if !found {
 		r.Token.Key = "konveyor"
 	}
+	s, found := os.LookupEnv(EnvBuiltinTokenLifespan)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Token.Lifespan = n
+	} else {
+		r.Token.Lifespan = 0 // unlimited.
+	}
 	r.RolePath, found = os.LookupEnv(EnvRolePath)
 	if !found {
 		r.RolePath = "/tmp/roles.yaml"
@@ -19,8 +19,20 @@ const (
 	EnvTaskReapFailed    = "TASK_REAP_FAILED"
 	EnvTaskSA            = "TASK_SA"
 	EnvTaskRetries       = "TASK_RETRIES"
+	EnvTaskExecutor      = "TASK_EXECUTOR"
+	EnvTaskProcessCmd    = "TASK_PROCESS_COMMAND"
+	EnvTaskProcessPath   = "TASK_PROCESS_PATH"
+	EnvTaskRuleUnique    = "TASK_RULE_UNIQUE"
+	EnvTaskLimitHub      = "TASK_LIMIT_HUB"
+	EnvTaskLimitAddon    = "TASK_LIMIT_ADDON"
+	EnvTaskLimitApp      = "TASK_LIMIT_APPLICATION"
+	EnvTaskFairShare     = "TASK_FAIR_SHARE"
+	EnvTaskAging         = "TASK_PRIORITY_AGING"
+	EnvTaskPreemption    = "TASK_PREEMPTION"
 	EnvFrequencyTask     = "FREQUENCY_TASK"
+	EnvFrequencyResync   = "FREQUENCY_TASK_RESYNC"
 	EnvFrequencyReaper   = "FREQUENCY_REAPER"
+	EnvFrequencySchedule = "FREQUENCY_SCHEDULE"
 	EnvDevelopment       = "DEVELOPMENT"
 	EnvBucketTTL         = "BUCKET_TTL"
 	EnvFileTTL           = "FILE_TTL"
@@ -57,19 +69,41 @@ type Hub struct {
 	}
 	// Task
 	Task struct {
-		SA      string
-		Retries int
-		Reaper  struct { // minutes.
+		SA       string
+		Retries  int
+		Executor string // pod|process
+		Process  struct {
+			Command string
+			Path    string
+		}
+		Reaper struct { // minutes.
 			Created   int
 			Succeeded int
 			Failed    int
 		}
+		Rule struct {
+			Unique bool
+		}
+		Limit struct { // (running+pending) 0=unlimited.
+			Hub         int
+			Addon       int
+			Application int
+		}
+		Queue struct {
+			FairShare bool
+			Aging     int // seconds (waited) per priority. 0=disabled.
+		}
+		Preemption struct {
+			Enabled bool
+		}
 	}
 	// Frequency
 	Frequency struct {
-		Task   int
-		Reaper int
-		Volume int
+		Task     int
+		Resync   int
+		Reaper   int
+		Schedule int
+		Volume   int
 	}
 	// Development environment
 	Development bool
@@ -148,6 +182,43 @@ func (r *Hub) Load() (err error) {
 	} else {
 		r.Task.Retries = 1
 	}
+	s, found = os.LookupEnv(EnvTaskRuleUnique)
+	if found {
+		b, _ := strconv.ParseBool(s)
+		r.Task.Rule.Unique = b
+	} else {
+		r.Task.Rule.Unique = true
+	}
+	s, found = os.LookupEnv(EnvTaskLimitHub)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Task.Limit.Hub = n
+	}
+	s, found = os.LookupEnv(EnvTaskLimitAddon)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Task.Limit.Addon = n
+	}
+	s, found = os.LookupEnv(EnvTaskLimitApp)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Task.Limit.Application = n
+	}
+	s, found = os.LookupEnv(EnvTaskFairShare)
+	if found {
+		b, _ := strconv.ParseBool(s)
+		r.Task.Queue.FairShare = b
+	}
+	s, found = os.LookupEnv(EnvTaskAging)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Task.Queue.Aging = n
+	}
+	s, found = os.LookupEnv(EnvTaskPreemption)
+	if found {
+		b, _ := strconv.ParseBool(s)
+		r.Task.Preemption.Enabled = b
+	}
 	s, found = os.LookupEnv(EnvFrequencyTask)
 	if found {
 		n, _ := strconv.Atoi(s)
@@ -155,6 +226,13 @@ func (r *Hub) Load() (err error) {
 	} else {
 		r.Frequency.Task = 1 // 1 second.
 	}
+	s, found = os.LookupEnv(EnvFrequencyResync)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Frequency.Resync = n
+	} else {
+		r.Frequency.Resync = 60 // 1 minute.
+	}
 	s, found = os.LookupEnv(EnvFrequencyReaper)
 	if found {
 		n, _ := strconv.Atoi(s)
@@ -162,6 +240,13 @@ func (r *Hub) Load() (err error) {
 	} else {
 		r.Frequency.Reaper = 1 // 1 minute.
 	}
+	s, found = os.LookupEnv(EnvFrequencySchedule)
+	if found {
+		n, _ := strconv.Atoi(s)
+		r.Frequency.Schedule = n
+	} else {
+		r.Frequency.Schedule = 30 // 30 seconds.
+	}
 	s, found = os.LookupEnv(EnvDevelopment)
 	if found {
 		b, _ := strconv.ParseBool(s)
@@ -190,6 +275,19 @@ func (r *Hub) Load() (err error) {
 		b, _ := strconv.ParseBool(s)
 		r.Disconnected = b
 	}
+	r.Task.Executor, found = os.LookupEnv(EnvTaskExecutor)
+	if !found {
+		if r.Disconnected {
+			r.Task.Executor = "process"
+		} else {
+			r.Task.Executor = "pod"
+		}
+	}
+	r.Task.Process.Command, _ = os.LookupEnv(EnvTaskProcessCmd)
+	r.Task.Process.Path, found = os.LookupEnv(EnvTaskProcessPath)
+	if !found {
+		r.Task.Process.Path = "/usr/local/bin"
+	}
 
 	return
 }
@@ -1,27 +1,21 @@
 package task
 
 import (
-	"context"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/konveyor/tackle2-hub/model"
 	"gorm.io/gorm"
-	core "k8s.io/api/core/v1"
-	"path"
-	k8s "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 //
 // Validator validates task tokens.
 type Validator struct {
-	// k8s client.
-	Client k8s.Client
 }
 
 //
 // Valid token when:
 //  - The token references a task.
 //  - The task is valid and running.
-//  - The task pod valid and pending|running.
+//  - The token has not been revoked.
 func (r *Validator) Valid(token *jwt.Token, db *gorm.DB) (valid bool) {
 	var err error
 	claims := token.Claims.(jwt.MapClaims)
@@ -44,31 +38,13 @@ func (r *Validator) Valid(token *jwt.Token, db *gorm.DB) (valid bool) {
 		Log.Info("Task referenced by token: not running.")
 		return
 	}
-	pod := &core.Pod{}
-	err = r.Client.Get(
-		context.TODO(),
-		k8s.ObjectKey{
-			Namespace: path.Dir(task.Pod),
-			Name:      path.Base(task.Pod),
-		},
-		pod)
-	if err != nil {
-		Log.Info(
-			"Pod referenced by token: not found.",
-			"name",
-			task.Pod)
-		return
-	}
-	switch pod.Status.Phase {
-	case core.PodPending,
-		core.PodRunning:
-	default:
+	v, found = claims["token"]
+	tokenID, cast := v.(string)
+	if !found || !cast || tokenID == "" || tokenID != task.TokenID {
 		Log.Info(
-			"Pod referenced by token: not running.",
-			"name",
-			task.Pod,
-			"phase",
-			pod.Status.Phase)
+			"Task token: revoked.",
+			"task",
+			task.ID)
 		return
 	}
 	valid = true
@@ -0,0 +1,35 @@
+package task
+
+import "io"
+
+//
+// Executors
+const (
+	ExecutorPod     = "pod"
+	ExecutorProcess = "process"
+)
+
+//
+// Executor runs (executes) tasks.
+type Executor interface {
+	// Run (start) the task.
+	Run(task *Task) (err error)
+	// Reflect updates the task to reflect the execution.
+	// Returns found=false when the execution is not found.
+	Reflect(task *Task) (found bool, err error)
+	// Delete the execution.
+	Delete(task *Task) (err error)
+	// Logs returns the execution logs.
+	// When follow=true, the logs are streamed until the
+	// execution has terminated.
+	Logs(task *Task, follow bool) (logs []ExecutionLog, err error)
+}
+
+//
+// ExecutionLog an execution (container) log.
+type ExecutionLog struct {
+	// Name (container).
+	Name string
+	// Reader the log content.
+	Reader io.ReadCloser
+}
@@ -2,6 +2,9 @@ package task
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
@@ -13,12 +16,16 @@ import (
 	"github.com/konveyor/tackle2-hub/model"
 	"github.com/konveyor/tackle2-hub/settings"
 	"gorm.io/gorm"
+	"io"
 	core "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
 	"path"
 	k8s "sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,19 +39,27 @@ const (
 	Running   = "Running"
 	Succeeded = "Succeeded"
 	Failed    = "Failed"
+	TimedOut  = "TimedOut"
 	Canceled  = "Canceled"
 )
 
 //
 // Policies
 const (
-	Isolated = "isolated"
+	Isolated       = "isolated"
+	NonPreemptible = "nonpreemptible"
 )
 
 const (
 	Unit = time.Second
 )
 
+//
+// LogDir bucket directory containing captured logs.
+const (
+	LogDir = "logs"
+)
+
 var (
 	Settings = &settings.Settings
 	Log      = logr.WithName("task-scheduler")
@@ -75,6 +90,12 @@ type Manager struct {
 	Client k8s.Client
 	// Addon token scopes.
 	Scopes []string
+	// cache (client) used to read watched pods.
+	cache k8s.Client
+	// synced (running tasks) timestamp.
+	synced time.Time
+	// mutex serializes the scheduler and pod events.
+	mutex sync.Mutex
 }
 
 //
@@ -82,9 +103,7 @@ type Manager struct {
 func (m *Manager) Run(ctx context.Context) {
 	auth.Validators = append(
 		auth.Validators,
-		&Validator{
-			Client: m.Client,
-		})
+		&Validator{})
 	go func() {
 		Log.Info("Started.")
 		defer Log.Info("Done.")
@@ -93,8 +112,13 @@ func (m *Manager) Run(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			default:
-				m.updateRunning()
+				m.mutex.Lock()
+				if m.resync() {
+					m.updateRunning()
+				}
+				m.enforceDeadlines()
 				m.startReady()
+				m.mutex.Unlock()
 				m.pause()
 			}
 		}
@@ -108,27 +132,36 @@ func (m *Manager) pause() {
 	time.Sleep(d)
 }
 
+//
+// resync determines if running tasks need to be updated
+// by polling their pods. When pods are watched, the pods are
+// only polled at the (slower) resync frequency as a fallback.
+func (m *Manager) resync() (needed bool) {
+	if m.cache == nil {
+		needed = true
+		return
+	}
+	d := Unit * time.Duration(Settings.Frequency.Resync)
+	if time.Since(m.synced) > d {
+		m.synced = time.Now()
+		needed = true
+	}
+	return
+}
+
 //
 // startReady starts pending tasks.
 func (m *Manager) startReady() {
-	list := []model.Task{}
-	db := m.DB.Order("priority DESC, id")
-	result := db.Find(
-		&list,
-		"state IN ?",
-		[]string{
-			Ready,
-			Postponed,
-			Pending,
-			Running,
-		})
-	Log.Error(result.Error, "")
-	if result.Error != nil {
+	queue := NewQueue()
+	list, err := queue.Queued(m.DB)
+	Log.Error(err, "")
+	if err != nil {
 		return
 	}
+	limits := m.addonLimits()
 	for i := range list {
 		task := &list[i]
-		if Settings.Disconnected {
+		if Settings.Disconnected && Settings.Hub.Task.Executor != ExecutorProcess {
 			mark := time.Now()
 			task.State = Failed
 			task.Terminated = &mark
@@ -145,7 +178,65 @@ func (m *Manager) startReady() {
 		case Ready,
 			Postponed:
 			ready := task
-			if m.postpone(ready, list) {
+			if ready.NextRetry != nil && ready.NextRetry.After(time.Now()) {
+				continue
+			}
+			dep, err := m.unmetDependency(ready)
+			if err != nil {
+				Log.Error(err, "")
+				continue
+			}
+			if dep != nil {
+				switch dep.State {
+				case Failed,
+					TimedOut,
+					Canceled:
+					mark := time.Now()
+					ready.Error(
+						"Error",
+						"Dependency: task (id=%d) %s.",
+						dep.ID,
+						strings.ToLower(dep.State))
+					ready.State = Failed
+					ready.Terminated = &mark
+					Log.Info(
+						"Task failed: dependency.",
+						"id",
+						ready.ID,
+						"dependency",
+						dep.ID)
+				default:
+					ready.State = Postponed
+					ready.Postpone("Dependency", dep.ID)
+					Log.Info(
+						"Task postponed: dependency.",
+						"id",
+						ready.ID,
+						"dependency",
+						dep.ID)
+				}
+				sErr := m.DB.Save(ready).Error
+				Log.Error(sErr, "")
+				continue
+			}
+			rt := Task{ready}
+			err = rt.Select(m.DB, m.Client)
+			if err != nil {
+				if errors.Is(err, &AddonNotMatched{}) {
+					mark := time.Now()
+					ready.Error("Error", err.Error())
+					ready.State = Failed
+					ready.Terminated = &mark
+					sErr := m.DB.Save(ready).Error
+					Log.Error(sErr, "")
+				}
+				Log.Error(err, "")
+				continue
+			}
+			if m.postpone(ready, list, limits) {
+				if Settings.Hub.Task.Preemption.Enabled {
+					m.preempt(ready, list)
+				}
 				ready.State = Postponed
 				Log.Info("Task postponed.", "id", ready.ID)
 				sErr := m.DB.Save(ready).Error
@@ -155,8 +246,7 @@ func (m *Manager) startReady() {
 			if ready.Retries == 0 {
 				metrics.TasksInitiated.Inc()
 			}
-			rt := Task{ready}
-			err := rt.Run(m.Client)
+			err = rt.Run(m.Client)
 			if err != nil {
 				if errors.Is(err, &AddonNotFound{}) {
 					ready.Error("Error", err.Error())
@@ -167,6 +257,8 @@ func (m *Manager) startReady() {
 				Log.Error(err, "")
 				continue
 			}
+			ready.Postponed = nil
+			ready.NextRetry = nil
 			Log.Info("Task started.", "id", ready.ID)
 			err = m.DB.Save(ready).Error
 			Log.Error(err, "")
@@ -178,11 +270,57 @@ func (m *Manager) startReady() {
 	}
 }
 
+//
+// preempt the (lower priority) task blocking the ready task.
+// The preempted task is returned to Ready and the ready
+// task is started when no longer blocked.
+func (m *Manager) preempt(ready *model.Task, list []model.Task) (preempted bool) {
+	postponed := model.TaskPostponed{}
+	_ = json.Unmarshal(ready.Postponed, &postponed)
+	for i := range list {
+		blocker := &list[i]
+		if blocker.ID != postponed.TaskID {
+			continue
+		}
+		switch blocker.State {
+		case Running,
+			Pending:
+		default:
+			return
+		}
+		if hasPolicy(blocker, NonPreemptible) {
+			return
+		}
+		queue := NewQueue()
+		if queue.Priority(blocker) >= queue.Priority(ready) {
+			return
+		}
+		rt := Task{blocker}
+		err := rt.Preempt(m.Client, ready)
+		if err != nil {
+			Log.Error(err, "")
+			return
+		}
+		err = m.DB.Save(blocker).Error
+		if err != nil {
+			Log.Error(err, "")
+			return
+		}
+		db := m.DB.Model(&model.TaskReport{})
+		err = db.Delete("taskid", blocker.ID).Error
+		Log.Error(err, "")
+		preempted = true
+		return
+	}
+	return
+}
+
 //
 // updateRunning tasks to reflect pod state.
 func (m *Manager) updateRunning() {
 	list := []model.Task{}
-	db := m.DB.Order("priority DESC, id")
+	db := m.DB.Preload("Bucket")
+	db = db.Order("priority DESC, id")
 	result := db.Find(
 		&list,
 		"state IN ?",
@@ -205,7 +343,7 @@ func (m *Manager) updateRunning() {
 			Log.Error(err, "")
 			continue
 		}
-		err = m.DB.Save(&running).Error
+		err = m.DB.Omit("Bucket").Save(&running).Error
 		if err != nil {
 			Log.Error(result.Error, "")
 			continue
@@ -214,13 +352,128 @@ func (m *Manager) updateRunning() {
 	}
 }
 
+//
+// enforceDeadlines terminates (pending|running) tasks that
+// have exceeded the deadline. Timed out tasks are retried only
+// when permitted by the retry policy.
+func (m *Manager) enforceDeadlines() {
+	list := []model.Task{}
+	db := m.DB.Preload("Bucket")
+	db = db.Where("Deadline > 0")
+	result := db.Find(
+		&list,
+		"state IN ?",
+		[]string{
+			Pending,
+			Running,
+		})
+	Log.Error(result.Error, "")
+	if result.Error != nil {
+		return
+	}
+	for i := range list {
+		task := &list[i]
+		if task.Started == nil {
+			continue
+		}
+		deadline := task.Started.Add(time.Duration(task.Deadline) * Unit)
+		if time.Now().Before(deadline) {
+			continue
+		}
+		rt := Task{task}
+		err := rt.TimeOut(m.Client)
+		Log.Error(err, "")
+		if err != nil {
+			continue
+		}
+		err = m.DB.Omit("Bucket").Save(task).Error
+		Log.Error(err, "")
+	}
+}
+
+//
+// unmetDependency returns a task on which the ready task depends
+// that has not succeeded. Failed and canceled dependencies are
+// returned in preference to dependencies still in progress.
+func (m *Manager) unmetDependency(ready *model.Task) (unmet *model.Task, err error) {
+	list := []model.Task{}
+	db := m.DB.Model(ready)
+	err = db.Association("DependsOn").Find(&list)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	previous, err := PreviousStage(m.DB, ready)
+	if err != nil {
+		return
+	}
+	if previous != nil {
+		list = append(list, *previous)
+	}
+	for i := range list {
+		dep := &list[i]
+		switch dep.State {
+		case Succeeded:
+		case Failed,
+			TimedOut,
+			Canceled:
+			unmet = dep
+			return
+		default:
+			if unmet == nil {
+				unmet = dep
+			}
+		}
+	}
+	return
+}
+
+//
+// PreviousStage returns the task for the previous pipeline stage.
+// Stages are sequential by application.
+func PreviousStage(db *gorm.DB, task *model.Task) (previous *model.Task, err error) {
+	if task.TaskGroupID == nil || task.Stage < 2 {
+		return
+	}
+	list := []model.Task{}
+	db = db.Preload("Report")
+	db = db.Where("TaskGroupID", *task.TaskGroupID)
+	db = db.Where("Stage", task.Stage-1)
+	if task.ApplicationID != nil {
+		db = db.Where("ApplicationID", *task.ApplicationID)
+	} else {
+		db = db.Where("ApplicationID IS NULL")
+	}
+	err = db.Find(&list).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	if len(list) > 0 {
+		previous = &list[0]
+	}
+	return
+}
+
 //
 // postpone Postpones a task as needed based on rules.
-func (m *Manager) postpone(ready *model.Task, list []model.Task) (postponed bool) {
+// The matched rule and (other) task are recorded on the task.
+func (m *Manager) postpone(ready *model.Task, list []model.Task, limits map[string]int) (postponed bool) {
 	ruleSet := []Rule{
 		&RuleIsolated{},
-		&RuleUnique{},
 	}
+	if Settings.Hub.Task.Rule.Unique {
+		ruleSet = append(
+			ruleSet,
+			&RuleUnique{})
+	}
+	ruleSet = append(
+		ruleSet,
+		&RuleLimit{
+			Hub:         Settings.Hub.Task.Limit.Hub,
+			Application: Settings.Hub.Task.Limit.Application,
+			Addon:       limits,
+		})
 	for i := range list {
 		other := &list[i]
 		if ready.ID == other.ID {
@@ -231,6 +484,7 @@ func (m *Manager) postpone(ready *model.Task, list []model.Task) (postponed bool
 			Pending:
 			for _, rule := range ruleSet {
 				if rule.Match(ready, other) {
+					ready.Postpone(rule.Name(), other.ID)
 					postponed = true
 					return
 				}
@@ -241,6 +495,30 @@ func (m *Manager) postpone(ready *model.Task, list []model.Task) (postponed bool
 	return
 }
 
+//
+// addonLimits returns the (running+pending) task limit by addon name.
+// Defaults to the hub setting when not specified by the addon.
+func (m *Manager) addonLimits() (limits map[string]int) {
+	limits = make(map[string]int)
+	list := crd.AddonList{}
+	err := m.Client.List(
+		context.TODO(),
+		&list,
+		&k8s.ListOptions{Namespace: Settings.Hub.Namespace})
+	if err != nil {
+		Log.Error(err, "")
+		return
+	}
+	for _, addon := range list.Items {
+		limit := addon.Spec.Limit
+		if limit == 0 {
+			limit = Settings.Hub.Task.Limit.Addon
+		}
+		limits[addon.Name] = limit
+	}
+	return
+}
+
 //
 // The task has been canceled.
 func (m *Manager) canceled(task *model.Task) {
@@ -265,6 +543,19 @@ type Task struct {
 	*model.Task
 }
 
+//
+// Submit prepares a submitted task.
+// The addon is selected (by kind) and the data validated.
+// Used by all paths by which tasks are submitted.
+func (r *Task) Submit(db *gorm.DB, client k8s.Client) (err error) {
+	err = r.Select(db, client)
+	if err != nil {
+		return
+	}
+	err = r.Validate(client)
+	return
+}
+
 //
 // Run the specified task.
 func (r *Task) Run(client k8s.Client) (err error) {
@@ -276,143 +567,212 @@ func (r *Task) Run(client k8s.Client) (err error) {
 			r.State = Failed
 		}
 	}()
-	addon, err := r.findAddon(client, r.Addon)
+	r.TokenID = r.newTokenID()
+	err = r.executor(client).Run(r)
 	if err != nil {
+		r.revoke()
 		return
 	}
-	owner, err := r.findTackle(client)
+	r.Started = &mark
+	r.State = Pending
+	return
+}
+
+//
+// Reflect updates the task state to reflect the execution.
+// The task is run again when the execution is not found.
+func (r *Task) Reflect(client k8s.Client) (err error) {
+	found, err := r.executor(client).Reflect(r)
 	if err != nil {
 		return
 	}
-	r.Image = addon.Spec.Image
-	secret := r.secret(addon)
-	err = client.Create(context.TODO(), &secret)
+	if !found {
+		r.Because("Execution not found.")
+		err = r.Run(client)
+	}
+	return
+}
+
+//
+// Delete the associated execution (pod) as needed.
+func (r *Task) Delete(client k8s.Client) (err error) {
+	r.revoke()
+	if r.Pod == "" {
+		return
+	}
+	err = r.executor(client).Delete(r)
 	if err != nil {
-		err = liberr.Wrap(err)
 		return
 	}
+	Log.Info(
+		"Task execution deleted.",
+		"id",
+		r.ID,
+		"pod",
+		r.Pod)
+	r.Pod = ""
+	mark := time.Now()
+	r.Terminated = &mark
+	return
+}
+
+//
+// Logs returns the execution logs.
+func (r *Task) Logs(client k8s.Client, follow bool) (logs []ExecutionLog, err error) {
+	logs, err = r.executor(client).Logs(r, follow)
+	return
+}
+
+//
+// capture writes (and closes) the execution logs into the
+// task bucket as: logs/<name>.<retries>.log so that the logs
+// of each attempt are kept. The bucket must be loaded.
+func (r *Task) capture(logs []ExecutionLog) {
 	defer func() {
-		if err != nil {
-			_ = client.Delete(context.TODO(), &secret)
+		for _, log := range logs {
+			_ = log.Reader.Close()
 		}
 	}()
-	pod := r.pod(addon, owner, &secret)
-	err = client.Create(context.TODO(), &pod)
+	if r.Bucket == nil {
+		return
+	}
+	dir := path.Join(r.Bucket.Path, LogDir)
+	err := os.MkdirAll(dir, 0777)
 	if err != nil {
-		err = liberr.Wrap(err)
+		Log.Error(err, "")
 		return
 	}
-	defer func() {
+	for _, log := range logs {
+		name := fmt.Sprintf("%s.%d.log", log.Name, r.Retries)
+		p := path.Join(dir, name)
+		f, err := os.Create(p)
 		if err != nil {
-			_ = client.Delete(context.TODO(), &pod)
+			Log.Error(err, "")
+			continue
 		}
-	}()
-	secret.OwnerReferences = append(
-		secret.OwnerReferences,
-		meta.OwnerReference{
-			APIVersion: "v1",
-			Kind:       "Pod",
-			Name:       pod.Name,
-			UID:        pod.UID,
-		})
-	err = client.Update(context.TODO(), &secret)
-	if err != nil {
-		err = liberr.Wrap(err)
-		return
+		_, err = io.Copy(f, log.Reader)
+		if err != nil {
+			Log.Error(err, "")
+		}
+		_ = f.Close()
 	}
-	r.Started = &mark
-	r.State = Pending
-	r.Pod = path.Join(
-		pod.Namespace,
-		pod.Name)
-	return
+	Log.V(1).Info(
+		"Task logs captured.",
+		"id",
+		r.ID,
+		"dir",
+		dir)
 }
 
 //
-// Reflect finds the associated pod and updates the task state.
-func (r *Task) Reflect(client k8s.Client) (err error) {
-	pod := &core.Pod{}
-	err = client.Get(
-		context.TODO(),
-		k8s.ObjectKey{
-			Namespace: path.Dir(r.Pod),
-			Name:      path.Base(r.Pod),
-		},
-		pod)
-	if err != nil {
-		if k8serr.IsNotFound(err) {
-			err = r.Run(client)
-		} else {
-			err = liberr.Wrap(err)
-		}
-		return
-	}
+// failed marks the task failed. The task is made ready to
+// be run again when retries are permitted by the retry policy.
+func (r *Task) failed(cause Cause, description string, x ...interface{}) (retried bool) {
 	mark := time.Now()
-	status := pod.Status
-	switch status.Phase {
-	case core.PodRunning:
-		r.State = Running
-	case core.PodSucceeded:
-		r.State = Succeeded
-		r.Terminated = &mark
-	case core.PodFailed:
-		r.Error("Error", "Pod failed: %s", pod.Status.Message)
-		if r.Retries < Settings.Hub.Task.Retries {
-			_ = client.Delete(context.TODO(), pod)
-			r.Pod = ""
-			r.State = Ready
-			r.Errors = nil
-			r.Retries++
-		} else {
-			r.State = Failed
-			r.Terminated = &mark
+	r.Error("Error", description, x...)
+	r.Because(description, x...)
+	r.revoke()
+	policy := RetryPolicy{}
+	policy.With(r.RetryPolicy)
+	if r.Retries < *policy.Limit && policy.Retryable(cause) {
+		r.Pod = ""
+		r.State = Ready
+		r.Errors = nil
+		r.Retries++
+		r.NextRetry = nil
+		delay := policy.Delay(r.Retries)
+		if delay > 0 {
+			next := mark.Add(delay)
+			r.NextRetry = &next
 		}
+		retried = true
+	} else {
+		r.State = Failed
+		r.Terminated = &mark
 	}
+	return
+}
 
+//
+// executor returns the task executor.
+func (r *Task) executor(client k8s.Client) (executor Executor) {
+	switch Settings.Hub.Task.Executor {
+	case ExecutorProcess:
+		executor = &ProcessExecutor{Client: client}
+	default:
+		executor = &PodExecutor{Client: client}
+	}
 	return
 }
 
 //
-// Delete the associated pod as needed.
-func (r *Task) Delete(client k8s.Client) (err error) {
-	if r.Pod == "" {
+// Cancel the task.
+func (r *Task) Cancel(client k8s.Client) (err error) {
+	err = r.Delete(client)
+	if err != nil {
 		return
 	}
-	pod := &core.Pod{}
-	pod.Namespace = path.Dir(r.Pod)
-	pod.Name = path.Base(r.Pod)
-	err = client.Delete(context.TODO(), pod)
+	r.State = Canceled
+	r.Because("Canceled.")
+	r.SetBucket(nil)
+	Log.Info(
+		"Task canceled.",
+		"id",
+		r.ID)
+	return
+}
+
+//
+// Preempt the task.
+// The execution is deleted and the task returned to Ready
+// without affecting the retry count.
+func (r *Task) Preempt(client k8s.Client, by *model.Task) (err error) {
+	err = r.Delete(client)
 	if err != nil {
-		if !k8serr.IsNotFound(err) {
-			err = liberr.Wrap(err)
-			return
-		} else {
-			err = nil
-		}
+		return
 	}
-	r.Pod = ""
+	r.State = Ready
+	r.Started = nil
+	r.Terminated = nil
+	r.Because("Preempted by task (id=%d).", by.ID)
 	Log.Info(
-		"Task pod deleted.",
+		"Task preempted.",
 		"id",
 		r.ID,
-		"pod",
-		pod.Name)
-	mark := time.Now()
-	r.Terminated = &mark
+		"by",
+		by.ID)
 	return
 }
 
 //
-// Cancel the task.
-func (r *Task) Cancel(client k8s.Client) (err error) {
+// TimeOut terminates the task that has exceeded the deadline.
+// The logs are captured before the execution is deleted. The
+// task is retried as permitted by the retry policy.
+func (r *Task) TimeOut(client k8s.Client) (err error) {
+	logs, err := r.Logs(client, false)
+	if err == nil {
+		r.capture(logs)
+	} else {
+		Log.Error(err, "")
+	}
 	err = r.Delete(client)
 	if err != nil {
 		return
 	}
-	r.State = Canceled
-	r.SetBucket(nil)
+	cause := Cause{Reason: DeadlineExceeded}
+	if r.failed(cause, "Deadline exceeded: %d seconds.", r.Deadline) {
+		r.Started = nil
+		r.Terminated = nil
+		Log.Info(
+			"Task timed out (retried).",
+			"id",
+			r.ID)
+		return
+	}
+	r.State = TimedOut
+	metrics.TasksTimedOut.Inc()
 	Log.Info(
-		"Task canceled.",
+		"Task timed out.",
 		"id",
 		r.ID)
 	return
@@ -510,7 +870,14 @@ func (r *Task) specification(addon *crd.Addon, secret *core.Secret) (specificati
 		Volumes: []core.Volume{
 			cache,
 		},
+		NodeSelector:    addon.Spec.NodeSelector,
+		Tolerations:     addon.Spec.Tolerations,
+		Affinity:        addon.Spec.Affinity,
+		SecurityContext: addon.Spec.PodSecurityContext,
 	}
+	specification.Volumes = append(
+		specification.Volumes,
+		addon.Spec.Volumes...)
 
 	return
 }
@@ -548,6 +915,17 @@ func (r *Task) container(addon *crd.Addon, secret *core.Secret) (container core.
 					},
 				},
 			},
+			{
+				Name: settings.EnvHubRefresh,
+				ValueFrom: &core.EnvVarSource{
+					SecretKeyRef: &core.SecretKeySelector{
+						Key: settings.EnvHubRefresh,
+						LocalObjectReference: core.LocalObjectReference{
+							Name: secret.Name,
+						},
+					},
+				},
+			},
 		},
 		VolumeMounts: []core.VolumeMount{
 			{
@@ -558,6 +936,16 @@ func (r *Task) container(addon *crd.Addon, secret *core.Secret) (container core.
 		SecurityContext: &core.SecurityContext{
 			RunAsUser: &userid,
 		},
+		EnvFrom: addon.Spec.EnvFrom,
+	}
+	container.Env = append(
+		container.Env,
+		addon.Spec.Env...)
+	container.VolumeMounts = append(
+		container.VolumeMounts,
+		addon.Spec.VolumeMounts...)
+	if addon.Spec.SecurityContext != nil {
+		container.SecurityContext = addon.Spec.SecurityContext
 	}
 
 	return
@@ -566,13 +954,7 @@ func (r *Task) container(addon *crd.Addon, secret *core.Secret) (container core.
 //
 // secret builds the pod secret.
 func (r *Task) secret(addon *crd.Addon) (secret core.Secret) {
-	user := "addon:" + addon.Name
-	token, _ := auth.Hub.NewToken(
-		user,
-		auth.AddonRole,
-		jwt.MapClaims{
-			"task": r.ID,
-		})
+	token, refresh := r.token(addon.Spec.Scopes)
 	secret = core.Secret{
 		ObjectMeta: meta.ObjectMeta{
 			Namespace:    Settings.Hub.Namespace,
@@ -580,13 +962,65 @@ func (r *Task) secret(addon *crd.Addon) (secret core.Secret) {
 			Labels:       r.labels(),
 		},
 		Data: map[string][]byte{
-			settings.EnvHubToken: []byte(token),
+			settings.EnvHubToken:   []byte(token),
+			settings.EnvHubRefresh: []byte(refresh),
 		},
 	}
 
 	return
 }
 
+//
+// token returns a new (addon) token and refresh token for the task.
+// The addon role is granted when scopes are not specified.
+// The (access) token expires after the configured lifespan
+// and is refreshed using the refresh token, which is valid
+// until revoked.
+func (r *Task) token(scopes []string) (token, refresh string) {
+	if len(scopes) == 0 {
+		scopes = auth.AddonRole
+	}
+	user := "addon:" + r.Addon
+	token, _ = auth.Hub.NewToken(
+		user,
+		scopes,
+		jwt.MapClaims{
+			"task":  r.ID,
+			"token": r.TokenID,
+		})
+	refresh, _ = auth.Hub.NewToken(
+		user,
+		scopes,
+		jwt.MapClaims{
+			"task":    r.ID,
+			"token":   r.TokenID,
+			"refresh": true,
+		})
+	return
+}
+
+//
+// newTokenID returns a new (random) token ID.
+func (r *Task) newTokenID() (id string) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id = hex.EncodeToString(b)
+	return
+}
+
+//
+// revoke the task tokens.
+func (r *Task) revoke() {
+	if r.TokenID == "" {
+		return
+	}
+	r.TokenID = ""
+	Log.V(1).Info(
+		"Task token revoked.",
+		"id",
+		r.ID)
+}
+
 //
 // k8sName returns a name suitable to be used for k8s resources.
 func (r *Task) k8sName() string {
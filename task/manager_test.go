@@ -0,0 +1,756 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/konveyor/tackle2-hub/database"
+	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
+	"github.com/konveyor/tackle2-hub/migration"
+	"github.com/konveyor/tackle2-hub/model"
+	"github.com/konveyor/tackle2-hub/settings"
+	"github.com/onsi/gomega"
+	"gorm.io/gorm"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	task := &model.Task{
+		Name:  "test",
+		State: Running,
+		Pod:   "tackle/task-1-abc",
+	}
+	err := db.Create(task).Error
+	g.Expect(err).To(gomega.BeNil())
+	pod := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace: path.Dir(task.Pod),
+			Name:      path.Base(task.Pod),
+			Labels:    (&Task{task}).labels(),
+		},
+		Status: core.PodStatus{
+			Phase: core.PodSucceeded,
+		},
+	}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(pod).
+		Build()
+	m := Manager{
+		DB:     db,
+		Client: client,
+		cache:  client,
+	}
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+		},
+	}
+	_, err = m.Reconcile(context.TODO(), request)
+	g.Expect(err).To(gomega.BeNil())
+	err = db.First(task, task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(task.State).To(gomega.Equal(Succeeded))
+	g.Expect(task.Terminated).ToNot(gomega.BeNil())
+	// Failed (retried).
+	Settings.Hub.Task.Retries = 1
+	task.State = Running
+	task.Terminated = nil
+	err = db.Save(task).Error
+	g.Expect(err).To(gomega.BeNil())
+	pod.Status.Phase = core.PodFailed
+	err = client.Status().Update(context.TODO(), pod)
+	g.Expect(err).To(gomega.BeNil())
+	_, err = m.Reconcile(context.TODO(), request)
+	g.Expect(err).To(gomega.BeNil())
+	err = db.First(task, task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(task.State).To(gomega.Equal(Ready))
+	g.Expect(task.Retries).To(gomega.Equal(1))
+	g.Expect(task.Pod).To(gomega.Equal(""))
+	// Events.
+	var events []model.TaskEvent
+	err = db.Order("ID").Find(&events, "TaskID", task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(events)).To(gomega.Equal(4))
+	g.Expect(events[0].State).To(gomega.Equal(Running))
+	g.Expect(events[1].Previous).To(gomega.Equal(Running))
+	g.Expect(events[1].State).To(gomega.Equal(Succeeded))
+	g.Expect(events[2].State).To(gomega.Equal(Running))
+	g.Expect(events[3].Previous).To(gomega.Equal(Running))
+	g.Expect(events[3].State).To(gomega.Equal(Ready))
+	g.Expect(events[3].Reason).To(gomega.HavePrefix("Pod failed:"))
+	g.Expect(events[3].Pod).To(gomega.Equal("tackle/task-1-abc"))
+	g.Expect(events[3].Retry).To(gomega.Equal(1))
+	// Not associated with a task.
+	request.Name = "other"
+	_, err = m.Reconcile(context.TODO(), request)
+	g.Expect(err).To(gomega.BeNil())
+}
+
+func TestProcess(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.Hub.Task.Process.Command = "true"
+	defer func() {
+		Settings.Hub.Task.Process.Command = ""
+	}()
+	task := &Task{&model.Task{Name: "test", Addon: "test"}}
+	task.ID = 1
+	err := crd.SchemeBuilder.AddToScheme(scheme.Scheme)
+	g.Expect(err).To(gomega.BeNil())
+	addon := &crd.Addon{}
+	addon.Namespace = Settings.Hub.Namespace
+	addon.Name = "test"
+	addon.Spec.Scopes = []string{"tasks:get"}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(addon).
+		Build()
+	executor := &ProcessExecutor{Client: client}
+	scopes, err := executor.scopes(task)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(scopes).To(gomega.Equal(addon.Spec.Scopes))
+	err = executor.Run(task)
+	g.Expect(err).To(gomega.BeNil())
+	p := processes.process[task.ID]
+	g.Expect(p).ToNot(gomega.BeNil())
+	g.Eventually(func() bool {
+		return !executor.Running(task)
+	}).Should(gomega.BeTrue())
+	found, err := executor.Reflect(task)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(found).To(gomega.BeTrue())
+	g.Expect(task.State).To(gomega.Equal(Succeeded))
+	// Deleted.
+	g.Expect(len(processes.process)).To(gomega.Equal(0))
+	_, err = os.Stat(p.dir)
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+}
+
+func TestTransition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	fetched := 0
+	err := db.Callback().Query().After("gorm:query").Register(
+		"test:fetched",
+		func(db *gorm.DB) {
+			if db.Statement.Table == "Task" {
+				fetched++
+			}
+		})
+	g.Expect(err).To(gomega.BeNil())
+	task := &model.Task{Name: "test", State: Ready}
+	err = db.Create(task).Error
+	g.Expect(err).To(gomega.BeNil())
+	// Loaded.
+	var list []model.Task
+	err = db.Find(&list).Error
+	g.Expect(err).To(gomega.BeNil())
+	fetched = 0
+	loaded := &list[0]
+	loaded.State = Running
+	err = db.Save(loaded).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(fetched).To(gomega.Equal(0))
+	// Not loaded; state not updated.
+	m := &model.Task{}
+	m.ID = task.ID
+	err = db.Model(m).Updates(map[string]interface{}{"Priority": 1}).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(fetched).To(gomega.Equal(0))
+	// Not loaded; state updated.
+	err = db.Model(m).Updates(map[string]interface{}{"State": Succeeded}).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(fetched).To(gomega.Equal(1))
+	var events []model.TaskEvent
+	err = db.Order("ID").Find(&events, "TaskID", task.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(events)).To(gomega.Equal(3))
+	g.Expect(events[1].Previous).To(gomega.Equal(Ready))
+	g.Expect(events[1].State).To(gomega.Equal(Running))
+	g.Expect(events[2].Previous).To(gomega.Equal(Running))
+}
+
+func TestCapture(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	dir, err := os.MkdirTemp("", "bucket")
+	g.Expect(err).To(gomega.BeNil())
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	task := &model.Task{
+		Name:  "test",
+		State: Running,
+		Pod:   "tackle/task-1-abc",
+	}
+	task.Bucket = &model.Bucket{Path: dir}
+	pod := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Namespace: path.Dir(task.Pod),
+			Name:      path.Base(task.Pod),
+		},
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{Name: "main"},
+			},
+		},
+		Status: core.PodStatus{
+			Phase: core.PodSucceeded,
+		},
+	}
+	pods := &PodExecutor{
+		Client:    fake.NewClientBuilder().Build(),
+		ClientSet: fakeset.NewSimpleClientset(pod),
+	}
+	pods.reflect(&Task{task}, pod)
+	g.Expect(task.State).To(gomega.Equal(Succeeded))
+	b, err := os.ReadFile(path.Join(dir, LogDir, "main.0.log"))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(b) > 0).To(gomega.BeTrue())
+	// Retried.
+	task.Retries = 1
+	pods.capture(&Task{task}, pod)
+	entries, err := os.ReadDir(path.Join(dir, LogDir))
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(entries)).To(gomega.Equal(2))
+	g.Expect(entries[1].Name()).To(gomega.Equal("main.1.log"))
+}
+
+func TestStream(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	task := &model.Task{
+		Name:  "test",
+		State: Created,
+	}
+	err := db.Create(task).Error
+	g.Expect(err).To(gomega.BeNil())
+	other := &model.Task{
+		Name:  "other",
+		State: Created,
+	}
+	err = db.Create(other).Error
+	g.Expect(err).To(gomega.BeNil())
+	last, err := Stream.latest(db)
+	g.Expect(err).To(gomega.BeNil())
+	subscriber := Stream.Subscribe(Filter{TaskID: task.ID})
+	defer Stream.Unsubscribe(subscriber)
+	for _, m := range []*model.Task{other, task} {
+		m.State = Ready
+		err = db.Save(m).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	// Rolled back.
+	err = db.Transaction(func(tx *gorm.DB) (err error) {
+		m := &model.Task{}
+		err = tx.First(m, task.ID).Error
+		if err != nil {
+			return
+		}
+		m.State = Running
+		err = tx.Save(m).Error
+		if err != nil {
+			return
+		}
+		err = errors.New("rollback")
+		return
+	})
+	g.Expect(err).ToNot(gomega.BeNil())
+	g.Expect(len(subscriber.Events)).To(gomega.Equal(0))
+	_, err = Stream.poll(db, last)
+	g.Expect(err).To(gomega.BeNil())
+	event := <-subscriber.Events
+	g.Expect(event.Kind).To(gomega.Equal(EventState))
+	g.Expect(event.TaskID).To(gomega.Equal(task.ID))
+	g.Expect(event.Previous).To(gomega.Equal(Created))
+	g.Expect(event.State).To(gomega.Equal(Ready))
+	g.Expect(len(subscriber.Events)).To(gomega.Equal(0))
+}
+
+func TestDeadline(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	started := time.Now().Add(-time.Hour)
+	expired := &model.Task{
+		Name:     "expired",
+		State:    Running,
+		Deadline: 60,
+		Pod:      "tackle/task-1-abc",
+	}
+	running := &model.Task{
+		Name:     "running",
+		State:    Running,
+		Deadline: 7200,
+		Pod:      "tackle/task-2-abc",
+	}
+	for _, task := range []*model.Task{expired, running} {
+		err := db.Create(task).Error
+		g.Expect(err).To(gomega.BeNil())
+		task.Started = &started
+		err = db.Save(task).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	m := Manager{
+		DB:     db,
+		Client: fake.NewClientBuilder().Build(),
+	}
+	m.enforceDeadlines()
+	err := db.First(expired, expired.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(expired.State).To(gomega.Equal(TimedOut))
+	g.Expect(expired.Terminated).ToNot(gomega.BeNil())
+	g.Expect(expired.Pod).To(gomega.Equal(""))
+	err = db.First(running, running.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(running.State).To(gomega.Equal(Running))
+	// Retried by policy.
+	retried := &model.Task{
+		Name:        "retried",
+		State:       Running,
+		Deadline:    60,
+		Pod:         "tackle/task-3-abc",
+		RetryPolicy: []byte(`{"limit": 1, "rules": [{"reason": "DeadlineExceeded", "retry": true}]}`),
+	}
+	err = db.Create(retried).Error
+	g.Expect(err).To(gomega.BeNil())
+	retried.Started = &started
+	err = db.Save(retried).Error
+	g.Expect(err).To(gomega.BeNil())
+	m.enforceDeadlines()
+	id := retried.ID
+	retried = &model.Task{}
+	err = db.First(retried, id).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(retried.State).To(gomega.Equal(Ready))
+	g.Expect(retried.Retries).To(gomega.Equal(1))
+	g.Expect(retried.Started).To(gomega.BeNil())
+	g.Expect(retried.Pod).To(gomega.Equal(""))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.Hub.Task.Retries = 1
+	policy := RetryPolicy{}
+	policy.With(nil)
+	g.Expect(*policy.Limit).To(gomega.Equal(1))
+	g.Expect(policy.Retryable(Cause{Reason: "Error", ExitCode: 1})).To(gomega.BeTrue())
+	g.Expect(policy.Retryable(Cause{Reason: "OOMKilled", ExitCode: 137})).To(gomega.BeFalse())
+	g.Expect(policy.Delay(1)).To(gomega.Equal(time.Duration(0)))
+	// Rules and backoff.
+	policy = RetryPolicy{}
+	policy.With([]byte(`
+{
+  "limit": 5,
+  "backoff": 10,
+  "maxBackoff": 60,
+  "rules": [
+    {"reason": "OOMKilled", "retry": true},
+    {"exitCodes": [2, 3], "retry": false}
+  ]
+}`))
+	g.Expect(*policy.Limit).To(gomega.Equal(5))
+	g.Expect(policy.Retryable(Cause{Reason: "OOMKilled"})).To(gomega.BeTrue())
+	g.Expect(policy.Retryable(Cause{Reason: "Error", ExitCode: 2})).To(gomega.BeFalse())
+	g.Expect(policy.Retryable(Cause{Reason: "Error", ExitCode: 1})).To(gomega.BeTrue())
+	g.Expect(policy.Delay(1)).To(gomega.Equal(10 * time.Second))
+	g.Expect(policy.Delay(2)).To(gomega.Equal(20 * time.Second))
+	g.Expect(policy.Delay(3)).To(gomega.Equal(40 * time.Second))
+	g.Expect(policy.Delay(4)).To(gomega.Equal(60 * time.Second))
+	g.Expect(policy.Delay(40)).To(gomega.Equal(60 * time.Second))
+	// Jitter.
+	policy.Jitter = 10
+	delay := policy.Delay(1)
+	g.Expect(delay >= 9*time.Second && delay <= 11*time.Second).To(gomega.BeTrue())
+	// Task.
+	task := &Task{&model.Task{}}
+	task.RetryPolicy = []byte(`{"limit": 2, "backoff": 10}`)
+	g.Expect(task.failed(Cause{Reason: "Error", ExitCode: 1}, "failed")).To(gomega.BeTrue())
+	g.Expect(task.State).To(gomega.Equal(Ready))
+	g.Expect(task.NextRetry).ToNot(gomega.BeNil())
+	g.Expect(task.failed(Cause{Reason: "OOMKilled"}, "failed")).To(gomega.BeFalse())
+	g.Expect(task.State).To(gomega.Equal(Failed))
+	// No retries.
+	Settings.Hub.Task.Retries = 3
+	policy = RetryPolicy{}
+	policy.With([]byte(`{"limit": 0}`))
+	g.Expect(*policy.Limit).To(gomega.Equal(0))
+	task = &Task{&model.Task{}}
+	task.RetryPolicy = []byte(`{"limit": 0}`)
+	g.Expect(task.failed(Cause{Reason: "Error", ExitCode: 1}, "failed")).To(gomega.BeFalse())
+	g.Expect(task.State).To(gomega.Equal(Failed))
+	g.Expect(task.Retries).To(gomega.Equal(0))
+}
+
+func TestResync(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	Settings.Frequency.Resync = 60
+	m := Manager{}
+	g.Expect(m.resync()).To(gomega.BeTrue())
+	g.Expect(m.resync()).To(gomega.BeTrue())
+	m.cache = fake.NewClientBuilder().Build()
+	g.Expect(m.resync()).To(gomega.BeTrue())
+	g.Expect(m.resync()).To(gomega.BeFalse())
+}
+
+//
+// setup the DB.
+func setup(g *gomega.WithT) (db *gorm.DB) {
+	Settings.DB.Path = "/tmp/task.db"
+	Settings.Bucket.Path = "/tmp/task/bucket"
+	_ = os.Remove(Settings.DB.Path)
+	err := migration.Migrate(migration.All())
+	g.Expect(err).To(gomega.BeNil())
+	db, err = database.Open(true)
+	g.Expect(err).To(gomega.BeNil())
+	return
+}
+
+func TestQueue(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	now := time.Now()
+	group := uint(1)
+	task := func(id uint, priority int, user string, group *uint, waited time.Duration) (m *model.Task) {
+		m = &model.Task{}
+		m.ID = id
+		m.Priority = priority
+		m.CreateUser = user
+		m.CreateTime = now.Add(-waited)
+		m.TaskGroupID = group
+		return
+	}
+	ids := func(list []*model.Task) (ids []uint) {
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+		return
+	}
+	list := []*model.Task{
+		task(1, 0, "a", &group, 0),
+		task(2, 0, "a", &group, 0),
+		task(3, 0, "a", nil, 0),
+		task(4, 0, "b", nil, 0),
+		task(5, 10, "b", nil, 0),
+		task(6, 0, "c", nil, time.Hour),
+	}
+	// Priority only.
+	queue := Queue{Now: now}
+	g.Expect(ids(queue.Order(list))).To(gomega.Equal([]uint{5, 1, 2, 3, 4, 6}))
+	// Fair share.
+	queue.FairShare = true
+	g.Expect(ids(queue.Order(list))).To(gomega.Equal([]uint{5, 1, 4, 6, 3, 2}))
+	// Aging.
+	queue.Aging = 60
+	g.Expect(queue.Priority(list[5])).To(gomega.Equal(60))
+	g.Expect(ids(queue.Order(list))).To(gomega.Equal([]uint{6, 5, 1, 4, 3, 2}))
+}
+
+func TestPreempt(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	started := time.Now()
+	running := &model.Task{
+		Name:    "running",
+		State:   Running,
+		Policy:  Isolated,
+		Pod:     "tackle/task-1-abc",
+		Retries: 1,
+	}
+	pinned := &model.Task{
+		Name:   "pinned",
+		State:  Running,
+		Policy: Isolated + ";" + NonPreemptible,
+		Pod:    "tackle/task-2-abc",
+	}
+	urgent := &model.Task{
+		Name:     "urgent",
+		State:    Ready,
+		Priority: 10,
+	}
+	for _, task := range []*model.Task{running, pinned, urgent} {
+		err := db.Create(task).Error
+		g.Expect(err).To(gomega.BeNil())
+		task.Started = &started
+		err = db.Save(task).Error
+		g.Expect(err).To(gomega.BeNil())
+	}
+	m := Manager{
+		DB:     db,
+		Client: fake.NewClientBuilder().Build(),
+	}
+	list := []model.Task{*running, *pinned}
+	// Non-preemptible.
+	urgent.Postpone("Isolated", pinned.ID)
+	g.Expect(m.preempt(urgent, list)).To(gomega.BeFalse())
+	// Preempted.
+	urgent.Postpone("Isolated", running.ID)
+	g.Expect(m.preempt(urgent, list)).To(gomega.BeTrue())
+	err := db.First(running, running.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(running.State).To(gomega.Equal(Ready))
+	g.Expect(running.Retries).To(gomega.Equal(1))
+	g.Expect(running.Pod).To(gomega.Equal(""))
+	events := []model.TaskEvent{}
+	err = db.Find(&events, "TaskID", running.ID).Error
+	g.Expect(err).To(gomega.BeNil())
+	last := events[len(events)-1]
+	g.Expect(last.State).To(gomega.Equal(Ready))
+	g.Expect(last.Reason).To(gomega.Equal(
+		fmt.Sprintf("Preempted by task (id=%d).", urgent.ID)))
+	// Lower priority.
+	list[0].State = Running
+	urgent.Priority = 0
+	g.Expect(m.preempt(urgent, list)).To(gomega.BeFalse())
+}
+
+func TestPreemptionEnabled(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	defer func() {
+		_ = os.Unsetenv(settings.EnvTaskPreemption)
+		Settings.Hub.Task.Preemption.Enabled = false
+	}()
+	addons := runtime.NewScheme()
+	err := scheme.AddToScheme(addons)
+	g.Expect(err).To(gomega.BeNil())
+	err = crd.SchemeBuilder.AddToScheme(addons)
+	g.Expect(err).To(gomega.BeNil())
+	for _, enabled := range []bool{false, true} {
+		err = os.Setenv(settings.EnvTaskPreemption, strconv.FormatBool(enabled))
+		g.Expect(err).To(gomega.BeNil())
+		err = Settings.Hub.Load()
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(Settings.Hub.Task.Preemption.Enabled).To(gomega.Equal(enabled))
+		db := setup(g)
+		running := &model.Task{
+			Name:   "running",
+			State:  Running,
+			Policy: Isolated,
+			Pod:    "tackle/task-1-abc",
+		}
+		urgent := &model.Task{
+			Name:     "urgent",
+			State:    Ready,
+			Priority: 10,
+			Addon:    "test",
+		}
+		for _, task := range []*model.Task{running, urgent} {
+			err = db.Create(task).Error
+			g.Expect(err).To(gomega.BeNil())
+		}
+		m := Manager{
+			DB:     db,
+			Client: fake.NewClientBuilder().WithScheme(addons).Build(),
+		}
+		m.startReady()
+		err = db.First(urgent, urgent.ID).Error
+		g.Expect(err).To(gomega.BeNil())
+		g.Expect(urgent.State).To(gomega.Equal(Postponed))
+		var preempted int64
+		db = db.Model(&model.TaskEvent{})
+		db = db.Where("TaskID", running.ID)
+		db = db.Where("Reason", fmt.Sprintf("Preempted by task (id=%d).", urgent.ID))
+		err = db.Count(&preempted).Error
+		g.Expect(err).To(gomega.BeNil())
+		if enabled {
+			g.Expect(preempted).To(gomega.Equal(int64(1)))
+		} else {
+			g.Expect(preempted).To(gomega.Equal(int64(0)))
+		}
+	}
+}
+
+func TestPodCustomization(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	user := int64(1000)
+	addon := &crd.Addon{}
+	addon.Spec.NodeSelector = map[string]string{"zone": "a"}
+	addon.Spec.Tolerations = []core.Toleration{{Key: "dedicated"}}
+	addon.Spec.Env = []core.EnvVar{{Name: "HTTPS_PROXY", Value: "proxy"}}
+	addon.Spec.Volumes = []core.Volume{{Name: "ca"}}
+	addon.Spec.VolumeMounts = []core.VolumeMount{{Name: "ca", MountPath: "/etc/ca"}}
+	addon.Spec.SecurityContext = &core.SecurityContext{RunAsUser: &user}
+	task := &Task{&model.Task{}}
+	spec := task.specification(addon, &core.Secret{})
+	g.Expect(spec.NodeSelector["zone"]).To(gomega.Equal("a"))
+	g.Expect(spec.Tolerations).To(gomega.Equal(addon.Spec.Tolerations))
+	g.Expect(len(spec.Volumes)).To(gomega.Equal(2))
+	g.Expect(spec.Volumes[1].Name).To(gomega.Equal("ca"))
+	container := spec.Containers[0]
+	g.Expect(container.Env[len(container.Env)-1].Name).To(gomega.Equal("HTTPS_PROXY"))
+	g.Expect(len(container.VolumeMounts)).To(gomega.Equal(2))
+	g.Expect(*container.SecurityContext.RunAsUser).To(gomega.Equal(user))
+}
+
+func TestValidate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	err := crd.SchemeBuilder.AddToScheme(scheme.Scheme)
+	g.Expect(err).To(gomega.BeNil())
+	addon := &crd.Addon{}
+	addon.Namespace = Settings.Hub.Namespace
+	addon.Name = "analyzer"
+	addon.Spec.Schema = &runtime.RawExtension{
+		Raw: []byte(`
+{
+  "type": "object",
+  "properties": {
+    "mode": {"type": "string"}
+  },
+  "required": ["mode"],
+  "additionalProperties": false
+}`),
+	}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(addon).
+		Build()
+	task := &Task{&model.Task{Name: "test", Addon: addon.Name}}
+	task.Data = []byte(`{"mode": "full"}`)
+	err = task.Validate(client)
+	g.Expect(err).To(gomega.BeNil())
+	task.Data = []byte(`{"mdoe": "full"}`)
+	err = task.Validate(client)
+	dErr := &DataError{}
+	g.Expect(errors.As(err, &dErr)).To(gomega.BeTrue())
+	g.Expect(len(dErr.Fields)).To(gomega.Equal(2))
+	// Addon not found.
+	task.Addon = "other"
+	err = task.Validate(client)
+	g.Expect(err).To(gomega.BeNil())
+}
+
+func TestSelect(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	category := &model.TagCategory{Name: "Platform"}
+	err := db.Create(category).Error
+	g.Expect(err).To(gomega.BeNil())
+	tag := &model.Tag{Name: "Quarkus", CategoryID: category.ID}
+	err = db.Create(tag).Error
+	g.Expect(err).To(gomega.BeNil())
+	application := &model.Application{Name: "test"}
+	err = db.Create(application).Error
+	g.Expect(err).To(gomega.BeNil())
+	err = db.Create(&model.ApplicationTag{
+		ApplicationID: application.ID,
+		TagID:         tag.ID,
+	}).Error
+	g.Expect(err).To(gomega.BeNil())
+	fact := &model.Fact{
+		ApplicationID: application.ID,
+		Key:           "language",
+		Source:        "test",
+		Value:         []byte(`"java"`),
+	}
+	err = db.Create(fact).Error
+	g.Expect(err).To(gomega.BeNil())
+	err = crd.SchemeBuilder.AddToScheme(scheme.Scheme)
+	g.Expect(err).To(gomega.BeNil())
+	addon := func(name string, selector *crd.AddonSelector) (addon *crd.Addon) {
+		addon = &crd.Addon{}
+		addon.Namespace = Settings.Hub.Namespace
+		addon.Name = name
+		addon.Spec.Kind = "analyzer"
+		addon.Spec.Selector = selector
+		return
+	}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(
+			addon("generic", nil),
+			addon("java", &crd.AddonSelector{Facts: []string{"language=Java"}}),
+			addon("quarkus", &crd.AddonSelector{
+				Tags:  []string{"platform=quarkus"},
+				Facts: []string{"language=java"},
+			}),
+			addon("dotnet", &crd.AddonSelector{Facts: []string{"language=C#"}})).
+		Build()
+	// Most specific.
+	task := &Task{&model.Task{Kind: "analyzer", ApplicationID: &application.ID}}
+	err = task.Select(db, client)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(task.Addon).To(gomega.Equal("quarkus"))
+	// No application.
+	task = &Task{&model.Task{Kind: "analyzer"}}
+	err = task.Select(db, client)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(task.Addon).To(gomega.Equal("generic"))
+	// Not matched.
+	task = &Task{&model.Task{Kind: "other", ApplicationID: &application.ID}}
+	err = task.Select(db, client)
+	g.Expect(errors.Is(err, &AddonNotMatched{})).To(gomega.BeTrue())
+}
+
+func TestPipeline(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	db := setup(g)
+	var applications []uint
+	for _, name := range []string{"a", "b"} {
+		application := &model.Application{Name: name}
+		err := db.Create(application).Error
+		g.Expect(err).To(gomega.BeNil())
+		applications = append(applications, application.ID)
+	}
+	group := &model.TaskGroup{
+		Name:   "pipeline",
+		State:  Ready,
+		Data:   []byte(`{"mode": "full"}`),
+		Stages: []byte(`[{"name": "fetch", "addon": "fetcher"}, {"name": "analyze", "addon": "analyzer", "data": {"deep": true}}]`),
+	}
+	for i := range applications {
+		group.Tasks = append(
+			group.Tasks,
+			model.Task{
+				Name:          "task",
+				ApplicationID: &applications[i],
+				Data:          []byte(`{}`),
+			})
+	}
+	err := group.Propagate()
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(len(group.Tasks)).To(gomega.Equal(4))
+	err = db.Create(group).Error
+	g.Expect(err).To(gomega.BeNil())
+	analyze := &group.Tasks[1]
+	g.Expect(analyze.Name).To(gomega.Equal("task.analyze"))
+	g.Expect(analyze.Addon).To(gomega.Equal("analyzer"))
+	g.Expect(analyze.Stage).To(gomega.Equal(2))
+	g.Expect(string(analyze.Data)).To(gomega.Equal(`{"deep":true,"mode":"full"}`))
+	m := Manager{DB: db}
+	// Previous stage not succeeded.
+	dep, err := m.unmetDependency(analyze)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(dep.ID).To(gomega.Equal(group.Tasks[0].ID))
+	// Previous stage succeeded.
+	fetch := &group.Tasks[0]
+	fetch.State = Succeeded
+	err = db.Save(fetch).Error
+	g.Expect(err).To(gomega.BeNil())
+	err = db.Create(&model.TaskReport{TaskID: fetch.ID, Result: []byte(`{"commit": "abc"}`)}).Error
+	g.Expect(err).To(gomega.BeNil())
+	dep, err = m.unmetDependency(analyze)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(dep).To(gomega.BeNil())
+	previous, err := PreviousStage(db, analyze)
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(previous.ID).To(gomega.Equal(fetch.ID))
+	g.Expect(string(previous.Report.Result)).To(gomega.Equal(`{"commit": "abc"}`))
+	g.Expect(string(analyze.Data)).To(gomega.Equal(`{"deep":true,"mode":"full"}`))
+	// Other application.
+	dep, err = m.unmetDependency(&group.Tasks[3])
+	g.Expect(err).To(gomega.BeNil())
+	g.Expect(dep.ID).To(gomega.Equal(group.Tasks[2].ID))
+}
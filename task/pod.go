@@ -0,0 +1,291 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	liberr "github.com/jortel/go-utils/error"
+	k8 "github.com/konveyor/tackle2-hub/k8s"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"path"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+	"time"
+)
+
+//
+// shared client set.
+var shared struct {
+	sync.Mutex
+	clientSet kubernetes.Interface
+}
+
+//
+// PodExecutor runs tasks as k8s pods.
+type PodExecutor struct {
+	// k8s client.
+	Client k8s.Client
+	// k8s client set (optional).
+	// Used to read pod logs.
+	ClientSet kubernetes.Interface
+}
+
+//
+// Run creates the task pod.
+func (e *PodExecutor) Run(task *Task) (err error) {
+	client := e.Client
+	addon, err := task.findAddon(client, task.Addon)
+	if err != nil {
+		return
+	}
+	owner, err := task.findTackle(client)
+	if err != nil {
+		return
+	}
+	task.Image = addon.Spec.Image
+	if task.Deadline == 0 {
+		task.Deadline = addon.Spec.Deadline
+	}
+	if task.RetryPolicy == nil && addon.Spec.Retry != nil {
+		task.RetryPolicy, _ = json.Marshal(addon.Spec.Retry)
+	}
+	secret := task.secret(addon)
+	err = client.Create(context.TODO(), &secret)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = client.Delete(context.TODO(), &secret)
+		}
+	}()
+	pod := task.pod(addon, owner, &secret)
+	err = client.Create(context.TODO(), &pod)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = client.Delete(context.TODO(), &pod)
+		}
+	}()
+	secret.OwnerReferences = append(
+		secret.OwnerReferences,
+		meta.OwnerReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       pod.Name,
+			UID:        pod.UID,
+		})
+	err = client.Update(context.TODO(), &secret)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	task.Pod = path.Join(
+		pod.Namespace,
+		pod.Name)
+	return
+}
+
+//
+// Reflect finds the associated pod and updates the task state.
+func (e *PodExecutor) Reflect(task *Task) (found bool, err error) {
+	pod := &core.Pod{}
+	err = e.Client.Get(
+		context.TODO(),
+		k8s.ObjectKey{
+			Namespace: path.Dir(task.Pod),
+			Name:      path.Base(task.Pod),
+		},
+		pod)
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			err = nil
+		} else {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	found = true
+	e.reflect(task, pod)
+	return
+}
+
+//
+// Delete the associated pod.
+func (e *PodExecutor) Delete(task *Task) (err error) {
+	pod := &core.Pod{}
+	pod.Namespace = path.Dir(task.Pod)
+	pod.Name = path.Base(task.Pod)
+	err = e.Client.Delete(context.TODO(), pod)
+	if err != nil {
+		if !k8serr.IsNotFound(err) {
+			err = liberr.Wrap(err)
+			return
+		} else {
+			err = nil
+		}
+	}
+	return
+}
+
+//
+// Logs returns the container logs.
+func (e *PodExecutor) Logs(task *Task, follow bool) (logs []ExecutionLog, err error) {
+	pod := &core.Pod{}
+	err = e.Client.Get(
+		context.TODO(),
+		k8s.ObjectKey{
+			Namespace: path.Dir(task.Pod),
+			Name:      path.Base(task.Pod),
+		},
+		pod)
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	logs, err = e.logs(pod, follow)
+	return
+}
+
+//
+// logs returns the pod container logs.
+func (e *PodExecutor) logs(pod *core.Pod, follow bool) (logs []ExecutionLog, err error) {
+	clientSet, err := e.clientSet()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			for _, log := range logs {
+				_ = log.Reader.Close()
+			}
+			logs = nil
+		}
+	}()
+	pods := clientSet.CoreV1().Pods(pod.Namespace)
+	for _, container := range pod.Spec.Containers {
+		request := pods.GetLogs(
+			pod.Name,
+			&core.PodLogOptions{
+				Container: container.Name,
+				Follow:    follow,
+			})
+		reader, sErr := request.Stream(context.TODO())
+		if sErr != nil {
+			err = liberr.Wrap(sErr)
+			return
+		}
+		logs = append(
+			logs,
+			ExecutionLog{
+				Name:   container.Name,
+				Reader: reader,
+			})
+	}
+	return
+}
+
+//
+// clientSet returns the client set.
+// When not specified, the shared client set is built
+// on first use.
+func (e *PodExecutor) clientSet() (clientSet kubernetes.Interface, err error) {
+	if e.ClientSet != nil {
+		clientSet = e.ClientSet
+		return
+	}
+	shared.Lock()
+	defer shared.Unlock()
+	if shared.clientSet == nil {
+		shared.clientSet, err = k8.NewClientSet()
+		if err != nil {
+			return
+		}
+	}
+	clientSet = shared.clientSet
+	return
+}
+
+//
+// reflect the pod state in the task.
+func (e *PodExecutor) reflect(task *Task, pod *core.Pod) {
+	mark := time.Now()
+	status := pod.Status
+	switch status.Phase {
+	case core.PodPending:
+		cause, failed := e.waiting(pod)
+		if failed {
+			task.failed(cause, "Pod failed: %s", cause.Reason)
+			_ = e.Client.Delete(context.TODO(), pod)
+		}
+	case core.PodRunning:
+		task.State = Running
+	case core.PodSucceeded:
+		task.State = Succeeded
+		task.Terminated = &mark
+		task.revoke()
+		e.capture(task, pod)
+	case core.PodFailed:
+		e.capture(task, pod)
+		cause := e.terminated(pod)
+		if task.failed(cause, "Pod failed: %s", pod.Status.Message) {
+			_ = e.Client.Delete(context.TODO(), pod)
+		}
+	}
+}
+
+//
+// capture the pod (container) logs.
+func (e *PodExecutor) capture(task *Task, pod *core.Pod) {
+	logs, err := e.logs(pod, false)
+	if err != nil {
+		Log.Error(err, "")
+		return
+	}
+	task.capture(logs)
+}
+
+//
+// waiting returns the cause of a pending pod that cannot be started.
+func (e *PodExecutor) waiting(pod *core.Pod) (cause Cause, failed bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		waiting := status.State.Waiting
+		if waiting == nil {
+			continue
+		}
+		switch waiting.Reason {
+		case "ErrImageNeverPull",
+			"ImagePullBackOff",
+			"InvalidImageName",
+			"CreateContainerConfigError":
+			cause.Reason = waiting.Reason
+			failed = true
+			return
+		}
+	}
+	return
+}
+
+//
+// terminated returns the cause of a failed pod.
+// The first container terminated with a non-zero exit code
+// is reported, else the pod reason.
+func (e *PodExecutor) terminated(pod *core.Pod) (cause Cause) {
+	cause.Reason = pod.Status.Reason
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		cause.Reason = terminated.Reason
+		cause.ExitCode = terminated.ExitCode
+		break
+	}
+	return
+}
@@ -0,0 +1,277 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/settings"
+	"os"
+	"os/exec"
+	"path"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// OutputLog process output (file) name.
+const OutputLog = "output.log"
+
+//
+// Processes (local) by task ID.
+var processes struct {
+	sync.Mutex
+	process map[uint]*Process
+}
+
+//
+// Process a task (local) process.
+type Process struct {
+	// command.
+	cmd *exec.Cmd
+	// working directory.
+	dir string
+	// done (exited).
+	done bool
+	// error reported on exit.
+	err error
+	// exited timestamp.
+	exited time.Time
+}
+
+//
+// ProcessExecutor runs tasks as local processes.
+// Used in development and when the hub is disconnected.
+// The addon command is either:
+//   - The configured command.
+//   - An executable (in the configured path) named for the addon.
+type ProcessExecutor struct {
+	// k8s client.
+	Client k8s.Client
+}
+
+//
+// Run starts the task process.
+// The process environment includes the same variables
+// injected into task pods.
+func (e *ProcessExecutor) Run(task *Task) (err error) {
+	processes.Lock()
+	defer processes.Unlock()
+	command, err := e.command(task.Addon)
+	if err != nil {
+		return
+	}
+	dir, err := os.MkdirTemp("", task.k8sName())
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	output, err := os.Create(path.Join(dir, OutputLog))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		err = liberr.Wrap(err)
+		return
+	}
+	scopes, err := e.scopes(task)
+	if err != nil {
+		_ = output.Close()
+		_ = os.RemoveAll(dir)
+		return
+	}
+	token, refresh := task.token(scopes)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.Env = append(
+		os.Environ(),
+		settings.EnvHubBaseURL+"="+Settings.Addon.Hub.URL,
+		settings.EnvTask+"="+strconv.Itoa(int(task.ID)),
+		settings.EnvHubToken+"="+token,
+		settings.EnvHubRefresh+"="+refresh)
+	err = cmd.Start()
+	if err != nil {
+		_ = output.Close()
+		_ = os.RemoveAll(dir)
+		err = liberr.Wrap(err)
+		return
+	}
+	p := &Process{cmd: cmd, dir: dir}
+	go func() {
+		pErr := cmd.Wait()
+		_ = output.Close()
+		processes.Lock()
+		defer processes.Unlock()
+		p.done = true
+		p.err = pErr
+		p.exited = time.Now()
+	}()
+	if processes.process == nil {
+		processes.process = make(map[uint]*Process)
+	}
+	processes.process[task.ID] = p
+	task.Image = cmd.Path
+	task.Pod = e.name(p)
+	return
+}
+
+//
+// Reflect finds the associated process and updates the task state.
+// Once exited, the output is captured and the process deleted.
+func (e *ProcessExecutor) Reflect(task *Task) (found bool, err error) {
+	processes.Lock()
+	defer processes.Unlock()
+	p, found := processes.process[task.ID]
+	if !found || task.Pod != e.name(p) {
+		found = false
+		return
+	}
+	if !p.done {
+		task.State = Running
+		return
+	}
+	e.capture(task, p)
+	e.delete(task.ID)
+	if p.err == nil {
+		task.Terminated = &p.exited
+		task.State = Succeeded
+		task.revoke()
+		return
+	}
+	cause := Cause{Reason: "Error"}
+	exitErr := &exec.ExitError{}
+	if errors.As(p.err, &exitErr) {
+		cause.ExitCode = int32(exitErr.ExitCode())
+	}
+	task.failed(cause, "Process failed: %s", p.err.Error())
+	return
+}
+
+//
+// Delete the associated process.
+// The process is killed as needed.
+func (e *ProcessExecutor) Delete(task *Task) (err error) {
+	processes.Lock()
+	defer processes.Unlock()
+	e.delete(task.ID)
+	return
+}
+
+//
+// Logs returns the process output.
+// Follow is not supported.
+func (e *ProcessExecutor) Logs(task *Task, follow bool) (logs []ExecutionLog, err error) {
+	processes.Lock()
+	defer processes.Unlock()
+	p, found := processes.process[task.ID]
+	if !found || task.Pod != e.name(p) {
+		err = liberr.Wrap(os.ErrNotExist)
+		return
+	}
+	logs, err = e.logs(p)
+	return
+}
+
+//
+// Running returns true when the process associated
+// with the task is running.
+func (e *ProcessExecutor) Running(task *Task) (running bool) {
+	processes.Lock()
+	defer processes.Unlock()
+	p, found := processes.process[task.ID]
+	if found {
+		running = !p.done && task.Pod == e.name(p)
+	}
+	return
+}
+
+//
+// logs returns the process output.
+func (e *ProcessExecutor) logs(p *Process) (logs []ExecutionLog, err error) {
+	f, err := os.Open(path.Join(p.dir, OutputLog))
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	logs = append(
+		logs,
+		ExecutionLog{
+			Name:   "main",
+			Reader: f,
+		})
+	return
+}
+
+//
+// capture the process output.
+func (e *ProcessExecutor) capture(task *Task, p *Process) {
+	logs, err := e.logs(p)
+	if err != nil {
+		Log.Error(err, "")
+		return
+	}
+	task.capture(logs)
+}
+
+//
+// delete (kill) the process and delete the working directory.
+func (e *ProcessExecutor) delete(id uint) {
+	p, found := processes.process[id]
+	if !found {
+		return
+	}
+	if !p.done {
+		err := p.cmd.Process.Kill()
+		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+			Log.Error(err, "")
+		}
+	}
+	err := os.RemoveAll(p.dir)
+	if err != nil {
+		Log.Error(err, "")
+	}
+	delete(processes.process, id)
+}
+
+//
+// scopes returns the addon (token) scopes.
+// The addon resource is optional.
+func (e *ProcessExecutor) scopes(task *Task) (scopes []string, err error) {
+	addon, err := task.findAddon(e.Client, task.Addon)
+	if err != nil {
+		if errors.Is(err, &AddonNotFound{}) {
+			err = nil
+		}
+		return
+	}
+	scopes = addon.Spec.Scopes
+	return
+}
+
+//
+// command returns the addon command.
+func (e *ProcessExecutor) command(addon string) (command []string, err error) {
+	if Settings.Hub.Task.Process.Command != "" {
+		command = strings.Fields(Settings.Hub.Task.Process.Command)
+		return
+	}
+	p := path.Join(Settings.Hub.Task.Process.Path, addon)
+	st, err := os.Stat(p)
+	if err != nil || st.IsDir() {
+		err = &AddonNotFound{addon}
+		return
+	}
+	command = []string{p}
+	return
+}
+
+//
+// name returns the process name.
+func (e *ProcessExecutor) name(p *Process) (name string) {
+	name = path.Join(
+		"process",
+		fmt.Sprintf("%d", p.cmd.Process.Pid))
+	return
+}
@@ -0,0 +1,159 @@
+package task
+
+import (
+	"fmt"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/model"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+//
+// Queue orders ready tasks to be started.
+// Tasks are ordered by effective priority. The effective priority
+// is the task priority aged by the time the task has waited. When
+// fair-share is enabled, tasks with the same effective priority
+// are interleaved (round-robin) by user and then by task group.
+type Queue struct {
+	// FairShare enabled.
+	FairShare bool
+	// Aging (seconds) waited per priority increment. 0=disabled.
+	Aging int
+	// Now the current time.
+	Now time.Time
+}
+
+//
+// NewQueue returns a queue configured by the hub settings.
+func NewQueue() (q *Queue) {
+	q = &Queue{
+		FairShare: Settings.Hub.Task.Queue.FairShare,
+		Aging:     Settings.Hub.Task.Queue.Aging,
+		Now:       time.Now(),
+	}
+	return
+}
+
+//
+// Priority returns the effective (aged) priority.
+func (q *Queue) Priority(task *model.Task) (priority int) {
+	priority = task.Priority
+	if q.Aging > 0 {
+		waited := q.Now.Sub(task.CreateTime)
+		aging := time.Duration(q.Aging) * Unit
+		if waited > 0 {
+			priority += int(waited / aging)
+		}
+	}
+	return
+}
+
+//
+// Order returns the tasks in the order to be started.
+func (q *Queue) Order(list []*model.Task) (ordered []*model.Task) {
+	ordered = make([]*model.Task, len(list))
+	copy(ordered, list)
+	sort.SliceStable(
+		ordered,
+		func(i, j int) bool {
+			pi := q.Priority(ordered[i])
+			pj := q.Priority(ordered[j])
+			if pi != pj {
+				return pi > pj
+			}
+			return ordered[i].ID < ordered[j].ID
+		})
+	if !q.FairShare {
+		return
+	}
+	begin := 0
+	for i := 1; i <= len(ordered); i++ {
+		if i < len(ordered) &&
+			q.Priority(ordered[i]) == q.Priority(ordered[begin]) {
+			continue
+		}
+		q.share(ordered[begin:i])
+		begin = i
+	}
+	return
+}
+
+//
+// Queued returns the unterminated (ready, postponed, pending
+// and running) tasks in the order considered by the scheduler.
+func (q *Queue) Queued(db *gorm.DB) (list []model.Task, err error) {
+	found := []model.Task{}
+	db = db.Order("priority DESC, id")
+	err = db.Find(
+		&found,
+		"state IN ?",
+		[]string{
+			Ready,
+			Postponed,
+			Pending,
+			Running,
+		}).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	tasks := []*model.Task{}
+	for i := range found {
+		tasks = append(tasks, &found[i])
+	}
+	for _, task := range q.Order(tasks) {
+		list = append(list, *task)
+	}
+	return
+}
+
+//
+// share reorders (in place) the tasks round-robin by
+// user and then by task group.
+func (q *Queue) share(band []*model.Task) {
+	type Group struct {
+		tasks []*model.Task
+	}
+	type User struct {
+		groups []*Group
+		next   int
+	}
+	var users []*User
+	userMap := make(map[string]*User)
+	groupMap := make(map[string]*Group)
+	for _, task := range band {
+		user, found := userMap[task.CreateUser]
+		if !found {
+			user = &User{}
+			userMap[task.CreateUser] = user
+			users = append(users, user)
+		}
+		key := task.CreateUser
+		if task.TaskGroupID != nil {
+			key = fmt.Sprintf("%s/%d", key, *task.TaskGroupID)
+		}
+		group, found := groupMap[key]
+		if !found {
+			group = &Group{}
+			groupMap[key] = group
+			user.groups = append(user.groups, group)
+		}
+		group.tasks = append(group.tasks, task)
+	}
+	ordered := make([]*model.Task, 0, len(band))
+	for len(ordered) < len(band) {
+		for _, user := range users {
+			for range user.groups {
+				group := user.groups[user.next%len(user.groups)]
+				user.next++
+				if len(group.tasks) > 0 {
+					ordered = append(ordered, group.tasks[0])
+					group.tasks = group.tasks[1:]
+					break
+				}
+			}
+		}
+	}
+	copy(band, ordered)
+}
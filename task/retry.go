@@ -0,0 +1,115 @@
+package task
+
+import (
+	"encoding/json"
+	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
+	"math/rand"
+	"time"
+)
+
+//
+// DeadlineExceeded the (cause) reason for timed out tasks.
+const DeadlineExceeded = "DeadlineExceeded"
+
+//
+// NotRetryable failure (termination) reasons.
+// Applied after the rules defined by the policy.
+var NotRetryable = []string{
+	DeadlineExceeded,
+	"ErrImageNeverPull",
+	"ImagePullBackOff",
+	"InvalidImageName",
+	"OOMKilled",
+}
+
+//
+// Cause of an execution failure.
+type Cause struct {
+	// Reason (container) termination reason.
+	Reason string
+	// ExitCode (container) exit code.
+	ExitCode int32
+}
+
+//
+// RetryPolicy determines if and when failed tasks are retried.
+type RetryPolicy struct {
+	crd.RetryPolicy
+}
+
+//
+// With the task (or addon) policy.
+// Hub defaults are applied when the limit is not specified.
+func (r *RetryPolicy) With(policy []byte) {
+	if policy != nil {
+		_ = json.Unmarshal(policy, &r.RetryPolicy)
+	}
+	if r.Limit == nil {
+		limit := Settings.Hub.Task.Retries
+		r.Limit = &limit
+	}
+}
+
+//
+// Retryable returns true when failures with the specified
+// cause may be retried.
+func (r *RetryPolicy) Retryable(cause Cause) (retryable bool) {
+	for _, rule := range r.Rules {
+		if r.match(&rule, cause) {
+			retryable = rule.Retry
+			return
+		}
+	}
+	for _, reason := range NotRetryable {
+		if cause.Reason == reason {
+			return
+		}
+	}
+	retryable = true
+	return
+}
+
+//
+// Delay returns the delay before the next retry.
+// The backoff is doubled for each retry and adjusted by the jitter.
+func (r *RetryPolicy) Delay(retries int) (delay time.Duration) {
+	if r.Backoff == 0 {
+		return
+	}
+	delay = time.Duration(r.Backoff) * Unit
+	for i := 1; i < retries; i++ {
+		delay *= 2
+		if r.MaxBackoff > 0 && delay > time.Duration(r.MaxBackoff)*Unit {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && delay > time.Duration(r.MaxBackoff)*Unit {
+		delay = time.Duration(r.MaxBackoff) * Unit
+	}
+	if r.Jitter > 0 {
+		span := int64(delay) * int64(r.Jitter) / 100
+		if span > 0 {
+			delay += time.Duration(rand.Int63n(2*span+1) - span)
+		}
+	}
+	return
+}
+
+//
+// match returns true when the rule matches the cause.
+func (r *RetryPolicy) match(rule *crd.RetryRule, cause Cause) (matched bool) {
+	if rule.Reason != "" && rule.Reason != cause.Reason {
+		return
+	}
+	if len(rule.ExitCodes) == 0 {
+		matched = true
+		return
+	}
+	for _, code := range rule.ExitCodes {
+		if code == cause.ExitCode {
+			matched = true
+			return
+		}
+	}
+	return
+}
@@ -8,6 +8,9 @@ import (
 //
 // Rule defines postpone rules.
 type Rule interface {
+	// Name returns the rule name.
+	Name() string
+	// Match determines if the candidate is postponed by the other task.
 	Match(candidate, other *model.Task) bool
 }
 
@@ -19,6 +22,12 @@ type Rule interface {
 type RuleUnique struct {
 }
 
+//
+// Name returns the rule name.
+func (r *RuleUnique) Name() string {
+	return "Unique"
+}
+
 //
 // Match determines the match.
 func (r *RuleUnique) Match(candidate, other *model.Task) (matched bool) {
@@ -47,10 +56,16 @@ func (r *RuleUnique) Match(candidate, other *model.Task) (matched bool) {
 type RuleIsolated struct {
 }
 
+//
+// Name returns the rule name.
+func (r *RuleIsolated) Name() string {
+	return "Isolated"
+}
+
 //
 // Match determines the match.
 func (r *RuleIsolated) Match(candidate, other *model.Task) (matched bool) {
-	matched = r.hasPolicy(candidate, Isolated) || r.hasPolicy(other, Isolated)
+	matched = hasPolicy(candidate, Isolated) || hasPolicy(other, Isolated)
 	if matched {
 		Log.Info(
 			"Rule:Isolated matched.",
@@ -64,8 +79,8 @@ func (r *RuleIsolated) Match(candidate, other *model.Task) (matched bool) {
 }
 
 //
-// Returns true if the task policy includes: isolated
-func (r *RuleIsolated) hasPolicy(task *model.Task, name string) (matched bool) {
+// hasPolicy returns true if the task policy includes the named policy.
+func hasPolicy(task *model.Task, name string) (matched bool) {
 	for _, p := range strings.Split(task.Policy, ";") {
 		p = strings.TrimSpace(p)
 		p = strings.ToLower(p)
@@ -77,3 +92,90 @@ func (r *RuleIsolated) hasPolicy(task *model.Task, name string) (matched bool) {
 
 	return
 }
+
+//
+// RuleLimit limits the number of (running+pending) tasks:
+//   - for the hub.
+//   - by addon.
+//   - by application.
+// The rule is stateful and must be built for each candidate.
+type RuleLimit struct {
+	// Hub limit. 0=unlimited.
+	Hub int
+	// Application limit. 0=unlimited.
+	Application int
+	// Addon limit (by addon name). 0=unlimited.
+	Addon map[string]int
+	// counted (running+pending) tasks.
+	counted struct {
+		hub         int
+		addon       int
+		application int
+	}
+}
+
+//
+// Name returns the rule name.
+func (r *RuleLimit) Name() string {
+	return "Limit"
+}
+
+//
+// Match determines the match.
+// Each (other) task is counted and the match reported
+// when the count reaches a limit.
+func (r *RuleLimit) Match(candidate, other *model.Task) (matched bool) {
+	r.counted.hub++
+	if r.Hub > 0 && r.counted.hub >= r.Hub {
+		matched = true
+		Log.Info(
+			"Rule:Limit matched (hub).",
+			"candidate",
+			candidate.ID,
+			"by",
+			other.ID,
+			"limit",
+			r.Hub)
+		return
+	}
+	if candidate.Addon == other.Addon {
+		r.counted.addon++
+		limit := r.Addon[candidate.Addon]
+		if limit > 0 && r.counted.addon >= limit {
+			matched = true
+			Log.Info(
+				"Rule:Limit matched (addon).",
+				"candidate",
+				candidate.ID,
+				"by",
+				other.ID,
+				"addon",
+				candidate.Addon,
+				"limit",
+				limit)
+			return
+		}
+	}
+	if candidate.ApplicationID == nil || other.ApplicationID == nil {
+		return
+	}
+	if *candidate.ApplicationID == *other.ApplicationID {
+		r.counted.application++
+		if r.Application > 0 && r.counted.application >= r.Application {
+			matched = true
+			Log.Info(
+				"Rule:Limit matched (application).",
+				"candidate",
+				candidate.ID,
+				"by",
+				other.ID,
+				"application",
+				*candidate.ApplicationID,
+				"limit",
+				r.Application)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,99 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/xeipuuv/gojsonschema"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+//
+// DataError reports task data not valid for the addon schema.
+type DataError struct {
+	// Task name.
+	Task string
+	// Addon name.
+	Addon string
+	// Fields the field-level errors.
+	Fields []FieldError
+}
+
+func (e *DataError) Error() (s string) {
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.String())
+	}
+	s = fmt.Sprintf(
+		"Task (%s) data not valid for addon (%s): %s",
+		e.Task,
+		e.Addon,
+		strings.Join(fields, "; "))
+	return
+}
+
+func (e *DataError) Is(err error) (matched bool) {
+	_, matched = err.(*DataError)
+	return
+}
+
+//
+// FieldError a field-level validation error.
+type FieldError struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+func (e *FieldError) String() string {
+	return e.Field + ": " + e.Description
+}
+
+//
+// Validate the task data using the schema published by the addon.
+// Tasks without an addon (selected), referencing addons that
+// cannot be found or do not publish a schema are not validated.
+func (r *Task) Validate(client k8s.Client) (err error) {
+	if r.Addon == "" {
+		return
+	}
+	addon, err := r.findAddon(client, r.Addon)
+	if err != nil {
+		if errors.Is(err, &AddonNotFound{}) {
+			err = nil
+		}
+		return
+	}
+	schema := addon.Spec.Schema
+	if schema == nil || len(schema.Raw) == 0 {
+		return
+	}
+	data := r.Data
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(schema.Raw),
+		gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		err = liberr.Wrap(err, "addon", addon.Name)
+		return
+	}
+	if result.Valid() {
+		return
+	}
+	dErr := &DataError{
+		Task:  r.Name,
+		Addon: addon.Name,
+	}
+	for _, re := range result.Errors() {
+		dErr.Fields = append(
+			dErr.Fields,
+			FieldError{
+				Field:       re.Field(),
+				Description: re.Description(),
+			})
+	}
+	err = dErr
+	return
+}
@@ -0,0 +1,167 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	liberr "github.com/jortel/go-utils/error"
+	crd "github.com/konveyor/tackle2-hub/k8s/api/tackle/v1alpha1"
+	"github.com/konveyor/tackle2-hub/model"
+	"gorm.io/gorm"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+//
+// AddonNotMatched used to report that no addon of the kind
+// referenced by a task matched the application.
+type AddonNotMatched struct {
+	Kind        string
+	Application *uint
+}
+
+func (e *AddonNotMatched) Error() (s string) {
+	if e.Application != nil {
+		s = fmt.Sprintf(
+			"Addon (kind=%s) not matched for application (id=%d).",
+			e.Kind,
+			*e.Application)
+	} else {
+		s = fmt.Sprintf(
+			"Addon (kind=%s) not matched.",
+			e.Kind)
+	}
+	return
+}
+
+func (e *AddonNotMatched) Is(err error) (matched bool) {
+	_, matched = err.(*AddonNotMatched)
+	return
+}
+
+//
+// Selector matches applications by tags and facts.
+type Selector struct {
+	crd.AddonSelector
+}
+
+//
+// Match returns true when all the tags and facts are matched
+// by the application.
+func (r *Selector) Match(application *model.Application) (matched bool) {
+	if application == nil {
+		matched = len(r.Tags) == 0 && len(r.Facts) == 0
+		return
+	}
+	tags := make(map[string]bool)
+	for _, tag := range application.Tags {
+		tags[strings.ToLower(tag.Name)] = true
+		if tag.Category.Name != "" {
+			ref := tag.Category.Name + "=" + tag.Name
+			tags[strings.ToLower(ref)] = true
+		}
+	}
+	for _, ref := range r.Tags {
+		if !tags[strings.ToLower(strings.TrimSpace(ref))] {
+			return
+		}
+	}
+	facts := make(map[string]bool)
+	for _, fact := range application.Facts {
+		var v interface{}
+		value := string(fact.Value)
+		err := json.Unmarshal(fact.Value, &v)
+		if err == nil {
+			if s, cast := v.(string); cast {
+				value = s
+			}
+		}
+		ref := fact.Key + "=" + value
+		facts[strings.ToLower(ref)] = true
+	}
+	for _, ref := range r.Facts {
+		if !facts[strings.ToLower(strings.TrimSpace(ref))] {
+			return
+		}
+	}
+	matched = true
+	return
+}
+
+//
+// Specificity returns the number of tags and facts to be matched.
+func (r *Selector) Specificity() int {
+	return len(r.Tags) + len(r.Facts)
+}
+
+//
+// Select the addon for a task naming a kind.
+// The most specific addon of the kind matching the
+// application is selected. Ties are resolved by name.
+func (r *Task) Select(db *gorm.DB, client k8s.Client) (err error) {
+	if r.Addon != "" || r.Kind == "" {
+		return
+	}
+	var application *model.Application
+	if r.ApplicationID != nil {
+		application = &model.Application{}
+		db = db.Preload("Tags.Category")
+		db = db.Preload("Facts")
+		err = db.First(application, *r.ApplicationID).Error
+		if err != nil {
+			err = liberr.Wrap(err)
+			return
+		}
+	}
+	list := crd.AddonList{}
+	err = client.List(
+		context.TODO(),
+		&list,
+		&k8s.ListOptions{Namespace: Settings.Hub.Namespace})
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	sort.Slice(
+		list.Items,
+		func(i, j int) bool {
+			return list.Items[i].Name < list.Items[j].Name
+		})
+	var selected *crd.Addon
+	specificity := -1
+	for i := range list.Items {
+		addon := &list.Items[i]
+		if addon.Spec.Kind != r.Kind {
+			continue
+		}
+		selector := Selector{}
+		if addon.Spec.Selector != nil {
+			selector.AddonSelector = *addon.Spec.Selector
+		}
+		if !selector.Match(application) {
+			continue
+		}
+		if selector.Specificity() > specificity {
+			specificity = selector.Specificity()
+			selected = addon
+		}
+	}
+	if selected == nil {
+		err = &AddonNotMatched{
+			Kind:        r.Kind,
+			Application: r.ApplicationID,
+		}
+		return
+	}
+	r.Addon = selected.Name
+	Log.Info(
+		"Addon selected.",
+		"id",
+		r.ID,
+		"kind",
+		r.Kind,
+		"addon",
+		r.Addon)
+	return
+}
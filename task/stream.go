@@ -0,0 +1,247 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/model"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+//
+// Stream event kinds.
+const (
+	EventState  = "state"
+	EventReport = "report"
+)
+
+//
+// MaxEvents the maximum number of events published per poll.
+const MaxEvents = 500
+
+//
+// Stream of task events published to subscribers.
+var Stream = &EventStream{}
+
+//
+// Event published to subscribers.
+type Event struct {
+	Kind        string      `json:"kind"`
+	TaskID      uint        `json:"task"`
+	TaskGroupID uint        `json:"taskGroup,omitempty"`
+	Application uint        `json:"application,omitempty"`
+	Previous    string      `json:"previous,omitempty"`
+	State       string      `json:"state,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+	Report      interface{} `json:"report,omitempty"`
+}
+
+//
+// Filter events. Zero values match all.
+type Filter struct {
+	TaskID      uint
+	TaskGroupID uint
+	Application uint
+}
+
+//
+// Match returns true when the event matches the filter.
+func (f *Filter) Match(event *Event) (matched bool) {
+	if f.TaskID > 0 && f.TaskID != event.TaskID {
+		return
+	}
+	if f.TaskGroupID > 0 && f.TaskGroupID != event.TaskGroupID {
+		return
+	}
+	if f.Application > 0 && f.Application != event.Application {
+		return
+	}
+	matched = true
+	return
+}
+
+//
+// Subscriber receives (filtered) events.
+type Subscriber struct {
+	Filter
+	// Events channel.
+	Events chan Event
+}
+
+//
+// EventStream delivers published events to subscribers.
+// Events are dropped for subscribers not keeping up.
+type EventStream struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscriber]bool
+}
+
+//
+// Subscribe returns a new subscriber.
+func (r *EventStream) Subscribe(filter Filter) (subscriber *Subscriber) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	subscriber = &Subscriber{
+		Filter: filter,
+		Events: make(chan Event, 100),
+	}
+	if r.subscribers == nil {
+		r.subscribers = make(map[*Subscriber]bool)
+	}
+	r.subscribers[subscriber] = true
+	return
+}
+
+//
+// Unsubscribe ends the subscription.
+func (r *EventStream) Unsubscribe(subscriber *Subscriber) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.subscribers[subscriber] {
+		delete(r.subscribers, subscriber)
+		close(subscriber.Events)
+	}
+}
+
+//
+// Publish an event.
+func (r *EventStream) Publish(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for subscriber := range r.subscribers {
+		if !subscriber.Match(&event) {
+			continue
+		}
+		select {
+		case subscriber.Events <- event:
+		default:
+			Log.V(1).Info(
+				"Event dropped: subscriber not ready.",
+				"task",
+				event.TaskID)
+		}
+	}
+}
+
+//
+// PublishTask publishes an event for the task.
+// The task group and application are set by the task.
+func (r *EventStream) PublishTask(db *gorm.DB, event Event) (err error) {
+	r.mutex.Lock()
+	n := len(r.subscribers)
+	r.mutex.Unlock()
+	if n == 0 {
+		return
+	}
+	task := &model.Task{}
+	db = db.Select("ID", "TaskGroupID", "ApplicationID")
+	err = db.Take(task, event.TaskID).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	if task.TaskGroupID != nil {
+		event.TaskGroupID = *task.TaskGroupID
+	}
+	if task.ApplicationID != nil {
+		event.Application = *task.ApplicationID
+	}
+	r.Publish(event)
+	return
+}
+
+//
+// Run publishes task state transitions (events).
+// The events are read from the DB and published once
+// committed so that transitions rolled back are not published.
+func (r *EventStream) Run(ctx context.Context, db *gorm.DB) {
+	go func() {
+		Log.Info("Event stream started.")
+		defer Log.Info("Event stream done.")
+		last, err := r.latest(db)
+		if err != nil {
+			Log.Error(err, "")
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				last, err = r.poll(db, last)
+				if err != nil {
+					Log.Error(err, "")
+				}
+				r.pause()
+			}
+		}
+	}()
+}
+
+//
+// poll publishes the events committed after the last
+// event (ID). Returns the ID of the last event published.
+func (r *EventStream) poll(db *gorm.DB, last uint) (latest uint, err error) {
+	latest = last
+	r.mutex.Lock()
+	n := len(r.subscribers)
+	r.mutex.Unlock()
+	if n == 0 {
+		latest, err = r.latest(db)
+		if err != nil || latest < last {
+			latest = last
+		}
+		return
+	}
+	var list []model.TaskEvent
+	db = db.Session(&gorm.Session{NewDB: true})
+	db = db.Where("ID > ?", last)
+	db = db.Order("ID")
+	db = db.Limit(MaxEvents)
+	err = db.Find(&list).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	for i := range list {
+		m := &list[i]
+		latest = m.ID
+		err = r.PublishTask(
+			db.Session(&gorm.Session{NewDB: true}),
+			Event{
+				Kind:     EventState,
+				TaskID:   m.TaskID,
+				Previous: m.Previous,
+				State:    m.State,
+				Reason:   m.Reason,
+			})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//
+// latest returns the ID of the latest (committed) event.
+func (r *EventStream) latest(db *gorm.DB) (id uint, err error) {
+	var max sql.NullInt64
+	db = db.Session(&gorm.Session{NewDB: true})
+	db = db.Model(&model.TaskEvent{})
+	db = db.Select("MAX(ID)")
+	err = db.Scan(&max).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	id = uint(max.Int64)
+	return
+}
+
+//
+// pause between polls.
+func (r *EventStream) pause() {
+	d := Unit * time.Duration(Settings.Frequency.Task)
+	time.Sleep(d)
+}
@@ -0,0 +1,120 @@
+package task
+
+import (
+	"context"
+	"errors"
+	liberr "github.com/jortel/go-utils/error"
+	"github.com/konveyor/tackle2-hub/model"
+	"gorm.io/gorm"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"path"
+	k8s "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+//
+// PodSelector selects task pods.
+func PodSelector() (selector labels.Selector) {
+	selector = labels.SelectorFromSet(
+		labels.Set{
+			"app":  "tackle",
+			"role": "task",
+		})
+	return
+}
+
+//
+// Watch task pods.
+// Pod events are reflected in the associated task as they
+// are received. Running tasks are still polled at the resync
+// frequency as a fallback.
+func (m *Manager) Watch(mgr manager.Manager) (err error) {
+	cnt, err := controller.New(
+		"task",
+		mgr,
+		controller.Options{
+			Reconciler: m,
+		})
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	selector := PodSelector()
+	err = cnt.Watch(
+		&source.Kind{Type: &core.Pod{}},
+		&handler.EnqueueRequestForObject{},
+		predicate.NewPredicateFuncs(
+			func(object k8s.Object) bool {
+				return selector.Matches(labels.Set(object.GetLabels()))
+			}))
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	m.cache = mgr.GetClient()
+	return
+}
+
+//
+// Reconcile a task pod event.
+// The task is found by pod and updated to reflect the pod state.
+func (m *Manager) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	task := &model.Task{}
+	db := m.DB.Preload("Bucket")
+	db = db.Where(
+		"pod",
+		path.Join(
+			request.Namespace,
+			request.Name))
+	err = db.First(task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		} else {
+			err = liberr.Wrap(err)
+		}
+		return
+	}
+	switch task.State {
+	case Pending,
+		Running:
+	default:
+		return
+	}
+	if task.Canceled {
+		m.canceled(task)
+		return
+	}
+	rt := Task{task}
+	pod := &core.Pod{}
+	err = m.cache.Get(ctx, request.NamespacedName, pod)
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			err = rt.Reflect(m.Client)
+		} else {
+			err = liberr.Wrap(err)
+		}
+	} else {
+		pods := &PodExecutor{Client: m.Client}
+		pods.reflect(&rt, pod)
+	}
+	if err != nil {
+		return
+	}
+	err = m.DB.Omit("Bucket").Save(task).Error
+	if err != nil {
+		err = liberr.Wrap(err)
+		return
+	}
+	Log.V(1).Info("Task updated.", "id", task.ID)
+	return
+}